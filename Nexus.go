@@ -2,11 +2,12 @@ package Nexus
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -17,16 +18,49 @@ const abortIndex int8 = math.MaxInt8 >> 1
 // Engine 是Nexus框架的核心结构
 type Engine struct {
 	RouterGroup
-	config       Config       // 配置
-	server       *http.Server // HTTP服务器
-	connections  map[*Connection]bool
-	broadcast    chan []byte
-	register     chan *Connection
-	unregister   chan *Connection
-	mu           sync.Mutex
+	config      Config       // 配置
+	server      *http.Server // HTTP服务器
+	connections map[*Connection]bool
+	connIndex   map[string]*Connection
+	broadcast   chan []byte
+	register    chan *Connection
+	unregister  chan *Connection
+	flush       chan chan struct{}
+	mu          sync.Mutex
+	// routesMu 保护路由树以及路由名称和元数据，路由可以在服务运行期间注册
+	routesMu     sync.RWMutex
 	trees        methodTrees
 	shuttingDown bool
 	shutdownChan chan struct{}
+	startedAt    time.Time
+	metrics      *metrics
+	loggers      map[string]*slog.Logger
+	accessLog    *accessLogger
+
+	namedRoutes map[string]routeKey
+	routeNames  map[routeKey]string
+	routeMeta   map[routeKey]*RouteMeta
+
+	nodeID             string
+	clusterUnsubscribe []func()
+	// deliveries 等待其他节点确认投递的消息
+	deliveriesMu sync.Mutex
+	deliveries   map[string]chan struct{}
+
+	roomsMu      sync.Mutex
+	rooms        map[string]*roomState
+	offlineRooms map[string]map[string]time.Time
+	storeMu      sync.Mutex
+	// replaying 正在补发离线消息的身份，由 storeMu 保护
+	replaying map[string]struct{}
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*session
+
+	hooksMu         sync.RWMutex
+	connectHooks    []ConnectHook
+	disconnectHooks []DisconnectHook
+	errorHooks      []ErrorHook
 }
 
 var _ IRouter = (*Engine)(nil)
@@ -38,18 +72,61 @@ func New() *Engine {
 
 // NewWithConfig 使用指定配置创建一个新的Nexus引擎实例
 func NewWithConfig(config Config) *Engine {
+	if config.SchemaConfig.Enabled && config.SchemaConfig.Path == "" {
+		config.SchemaConfig.Path = defaultSchemaPath
+	}
 	e := &Engine{
 		config:       config,
 		trees:        make(methodTrees, 0, 9),
+		namedRoutes:  make(map[string]routeKey),
+		routeNames:   make(map[routeKey]string),
+		routeMeta:    make(map[routeKey]*RouteMeta),
 		connections:  make(map[*Connection]bool),
+		connIndex:    make(map[string]*Connection),
+		rooms:        make(map[string]*roomState),
+		offlineRooms: make(map[string]map[string]time.Time),
+		replaying:    make(map[string]struct{}),
+		sessions:     make(map[string]*session),
+		deliveries:   make(map[string]chan struct{}),
 		broadcast:    make(chan []byte),
 		register:     make(chan *Connection),
 		unregister:   make(chan *Connection),
+		flush:        make(chan chan struct{}),
 		shutdownChan: make(chan struct{}),
+		nodeID:       GenerateUniqueString(),
+		startedAt:    time.Now(),
+		loggers:      newLoggers(config.LogConfig),
+		accessLog:    newAccessLogger(config.AccessLogConfig),
 	}
 	e.RouterGroup.engine = e
 	e.RouterGroup.root = true
 
+	if config.MetricsConfig.Enabled {
+		e.metrics = newMetrics(config.MetricsConfig.Buckets)
+	}
+	if config.AdminConfig.Routes {
+		e.registerAdminRoutes()
+	}
+	if config.SchemaConfig.Enabled {
+		e.registerSchemaRoutes()
+	}
+
+	// 订阅集群频道
+	e.startCluster()
+
+	// 启动会话清理协程
+	if config.SessionConfig.Enabled {
+		go e.expireSessions()
+	}
+
+	// 恢复离线房间记录并启动清理协程
+	if config.OfflineConfig.Store != nil {
+		e.restoreOfflineRooms()
+		if config.OfflineConfig.RoomRetention > 0 {
+			go e.expireOfflineRooms()
+		}
+	}
+
 	// 启动主循环协程
 	go e.run()
 
@@ -85,6 +162,17 @@ func (e *Engine) Run(args ...string) error {
 	// 创建HTTP服务器
 	mux := http.NewServeMux()
 	mux.HandleFunc(path, e.WebSocketService())
+	if e.config.MetricsConfig.Enabled {
+		mux.Handle(e.config.MetricsConfig.Path, e.MetricsHandler())
+	}
+	if e.config.AdminConfig.Enabled {
+		adminPath := strings.TrimSuffix(e.config.AdminConfig.Path, "/")
+		mux.Handle(adminPath, e.AdminHandler())
+		mux.Handle(adminPath+"/", e.AdminHandler())
+	}
+	if e.config.SchemaConfig.Enabled {
+		mux.Handle(e.config.SchemaConfig.Path, e.SchemaHandler())
+	}
 
 	e.server = &http.Server{
 		Addr:    addr,
@@ -95,20 +183,17 @@ func (e *Engine) Run(args ...string) error {
 	go e.gracefulShutdown()
 
 	// 启动服务器
-	if e.config.LogConfig.Debug {
-		log.Printf("[INFO] Server starting on %s with WebSocket path %s", addr, path)
-	}
+	e.debugPrintRoutes()
+	e.log(LogEngine).Info("server starting", "addr", addr, "path", path)
 
 	if err := e.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Printf("[ERROR] ListenAndServe: %v", err)
+		e.log(LogEngine).Error("listen and serve failed", "error", err)
 		return err
 	}
 
 	// 等待优雅关闭完成
 	<-e.shutdownChan
-	if e.config.LogConfig.Debug {
-		log.Printf("[INFO] Server stopped gracefully")
-	}
+	e.log(LogEngine).Info("server stopped gracefully")
 
 	return nil
 }
@@ -120,38 +205,50 @@ func (e *Engine) run() {
 		case conn := <-e.register:
 			e.mu.Lock()
 			e.connections[conn] = true
+			e.connIndex[conn.id] = conn
+			total := len(e.connections)
 			e.mu.Unlock()
-			if e.config.LogConfig.Debug {
-				log.Printf("[INFO] Client connected, total connections: %d", len(e.connections))
-			}
+			e.log(LogConnection).Debug("client connected", "conn", conn.id, "connections", total)
 		case conn := <-e.unregister:
 			e.mu.Lock()
 			if _, ok := e.connections[conn]; ok {
 				delete(e.connections, conn)
-				close(conn.send)
-				if e.config.LogConfig.Debug {
-					log.Printf("[INFO] Client disconnected, total connections: %d", len(e.connections))
-				}
+				delete(e.connIndex, conn.id)
+				e.log(LogConnection).Debug("client disconnected", "conn", conn.id, "connections", len(e.connections))
 			}
 			e.mu.Unlock()
 		case message := <-e.broadcast:
 			e.mu.Lock()
 			for conn := range e.connections {
-				select {
-				case conn.send <- message:
-				default:
-					close(conn.send)
-					delete(e.connections, conn)
+				// 可靠投递模式下由重试机制处理发送通道已满的情况
+				if conn.reliable != nil {
+					conn.Send(message)
+					continue
+				}
+				if err := conn.enqueue(message); err != nil {
+					// 发送通道已满，异步关闭连接，由注销流程清理
+					e.metrics.drop()
+					go conn.close()
 				}
 			}
 			e.mu.Unlock()
+		case done := <-e.flush:
+			// 此前收到的广播都已放入发送通道
+			close(done)
 		}
 	}
 }
 
-// Broadcast 向所有连接的客户端广播消息
+// Broadcast 向所有连接的客户端广播消息，配置了Broker时同时广播到其他节点
 func (e *Engine) Broadcast(message []byte) {
+	e.broadcastLocal(message)
+	e.publish(channelBroadcast, "", message)
+}
+
+// broadcastLocal 向本节点的所有连接广播消息，已断开的会话会缓存该消息
+func (e *Engine) broadcastLocal(message []byte) {
 	e.broadcast <- message
+	e.bufferDetached(message, func(*Connection) bool { return true })
 }
 
 // gracefulShutdown 处理服务器的优雅关闭
@@ -161,9 +258,7 @@ func (e *Engine) gracefulShutdown() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	if e.config.LogConfig.Debug {
-		log.Printf("[INFO] Server is shutting down...")
-	}
+	e.log(LogEngine).Info("server shutting down")
 
 	e.shuttingDown = true
 
@@ -173,15 +268,16 @@ func (e *Engine) gracefulShutdown() {
 
 	// 关闭HTTP服务器
 	if err := e.server.Shutdown(ctx); err != nil {
-		log.Printf("[ERROR] Server shutdown error: %v", err)
+		e.log(LogEngine).Error("server shutdown failed", "error", err)
 	}
 
-	// 关闭所有WebSocket连接
-	e.mu.Lock()
-	for conn := range e.connections {
-		conn.close()
+	// 停止接收其他节点的消息
+	e.stopCluster()
+
+	// 关闭所有连接
+	for _, conn := range e.Connections() {
+		conn.closeWithReason(CloseGoingAway, "server shutting down")
 	}
-	e.mu.Unlock()
 
 	// 发送关闭完成信号
 	close(e.shutdownChan)
@@ -189,14 +285,14 @@ func (e *Engine) gracefulShutdown() {
 
 // addRoute 添加路由处理函数
 func (e *Engine) addRoute(method, path string, handlers HandlerFuncList) {
-	if e.config.LogConfig.Debug {
-		log.Printf("[DEBUG] Route added: %s %s", method, path)
-	}
+	e.log(LogRouter).Debug("route added", "method", method, "path", path)
 
 	assert1(path[0] == '/', "path must begin with '/'")
 	assert1(method != "", "HTTP method can not be empty")
 	assert1(len(handlers) > 0, "there must be at least one handler")
 
+	e.routesMu.Lock()
+	defer e.routesMu.Unlock()
 	root := e.trees.get(method)
 	if root == nil {
 		root = new(node)
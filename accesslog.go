@@ -0,0 +1,245 @@
+package Nexus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// 预置的访问日志模板
+const (
+	// AccessLogCombined 类似HTTP combined格式的单行文本
+	AccessLogCombined = `{{.RemoteAddr | dash}} {{.Identity | dash}} [{{.Time.Format "02/Jan/2006:15:04:05 -0700"}}] "{{.Method}} {{.Path}}" {{.Status}} {{.RequestSize}} {{.ResponseSize}} {{.Latency}} {{.RequestID | dash}} {{.Route | dash}}`
+	// AccessLogJSON 每条记录输出一行JSON
+	AccessLogJSON = "json"
+)
+
+// AccessLogEntry 一次请求的访问日志记录，自定义模板可以引用其中的字段
+type AccessLogEntry struct {
+	// 请求完成的时间
+	Time time.Time `json:"time"`
+	// 请求ID
+	RequestID string `json:"request_id"`
+	// 连接ID
+	ConnID string `json:"conn"`
+	// 对端地址
+	RemoteAddr string `json:"remote_addr"`
+	// 连接绑定的用户身份，未绑定时为空
+	Identity string `json:"identity,omitempty"`
+	// 请求方法
+	Method string `json:"method"`
+	// 请求路径
+	Path string `json:"path"`
+	// 匹配到的路由完整路径，未匹配时为空
+	Route string `json:"route,omitempty"`
+	// 响应状态码
+	Status int `json:"status"`
+	// 请求消息的字节数
+	RequestSize int `json:"request_size"`
+	// 响应消息的字节数
+	ResponseSize int `json:"response_size"`
+	// 从收到消息到发出响应的耗时
+	Latency time.Duration `json:"-"`
+	// 追踪ID，未启用追踪时为空
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// MarshalJSON 以毫秒输出耗时
+func (a AccessLogEntry) MarshalJSON() ([]byte, error) {
+	type entry AccessLogEntry
+	return json.Marshal(struct {
+		entry
+		LatencyMs float64 `json:"latency_ms"`
+	}{entry(a), float64(a.Latency) / float64(time.Millisecond)})
+}
+
+// accessLogger 按配置的模板写出访问日志，Format为空时为nil，由access子系统的slog日志记录器输出
+type accessLogger struct {
+	mu   sync.Mutex
+	out  io.Writer
+	tmpl *template.Template
+	json bool
+}
+
+// newAccessLogger 创建访问日志写出器，模板不合法时panic
+func newAccessLogger(cfg AccessLogConfig) *accessLogger {
+	if cfg.Format == "" {
+		return nil
+	}
+	out := cfg.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	l := &accessLogger{out: out}
+	if cfg.Format == AccessLogJSON {
+		l.json = true
+		return l
+	}
+	l.tmpl = template.Must(template.New("access").Funcs(template.FuncMap{
+		"dash": func(s string) string {
+			if s == "" {
+				return "-"
+			}
+			return s
+		},
+	}).Parse(cfg.Format))
+	return l
+}
+
+// write 写出一行访问日志
+func (l *accessLogger) write(entry *AccessLogEntry) error {
+	var buf bytes.Buffer
+	if l.json {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	} else if err := l.tmpl.Execute(&buf, entry); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.out.Write(buf.Bytes())
+	return err
+}
+
+// logAccess 在响应发出后记录访问日志
+func (e *Engine) logAccess(c *Context, requestSize, responseSize int, elapsed time.Duration) {
+	entry := &AccessLogEntry{
+		Time:         time.Now(),
+		RequestID:    c.Request.ID,
+		Method:       c.Request.Method,
+		Path:         c.Request.Path,
+		Route:        c.fullPath,
+		RequestSize:  requestSize,
+		ResponseSize: responseSize,
+		Latency:      elapsed,
+	}
+	if c.connection != nil {
+		entry.ConnID = c.connection.id
+		entry.RemoteAddr = c.connection.RemoteAddr()
+		entry.Identity = c.connection.Identity()
+	}
+	if c.Response != nil {
+		entry.Status = int(c.Response.Status)
+	}
+	if c.span != nil {
+		entry.TraceID = c.span.TraceID
+	}
+
+	if e.accessLog == nil {
+		e.log(LogAccess).Info("request",
+			"request_id", entry.RequestID,
+			"conn", entry.ConnID,
+			"remote", entry.RemoteAddr,
+			"identity", entry.Identity,
+			"method", entry.Method,
+			"path", entry.Path,
+			"route", entry.Route,
+			"status", entry.Status,
+			"request_size", entry.RequestSize,
+			"response_size", entry.ResponseSize,
+			"latency", entry.Latency,
+		)
+		return
+	}
+	if err := e.accessLog.write(entry); err != nil {
+		e.log(LogEngine).Error("access log write failed", "error", err)
+	}
+}
+
+// RotatingFile 按大小轮转的日志文件，可作为 AccessLogConfig.Output 或 LogConfig.Output
+// 写入后文件超过上限时，当前文件重命名为 path.1，原有的 path.1 顺延为 path.2，依此类推
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile 打开或创建日志文件
+// maxSize 为单个文件的字节数上限，<=0 时不轮转；maxBackups 为保留的历史文件数，<=0 时轮转后直接删除旧文件
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open 以追加模式打开日志文件
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write 写入日志，超过大小上限时先轮转
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件并顺延历史文件，调用方需持有锁
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.maxBackups <= 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return f.open()
+}
+
+// Close 关闭日志文件
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
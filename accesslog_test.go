@@ -0,0 +1,181 @@
+package Nexus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer 并发安全的缓冲区，测试读取时访问日志可能仍在写入
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines 返回已写入的完整行
+func (b *lockedBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := strings.TrimSuffix(b.buf.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// accessLogLines 使用指定模板处理一个匹配路由的请求和一个未匹配的请求，返回写出的两行访问日志
+func accessLogLines(t *testing.T, format string) []string {
+	t.Helper()
+	out := &lockedBuffer{}
+	cfg := DefaultConfig()
+	cfg.LogConfig.AccessLog = true
+	cfg.AccessLogConfig.Format = format
+	cfg.AccessLogConfig.Output = out
+	e := NewWithConfig(cfg)
+	e.GET("/users/:id", func(c *Context) { c.JSON(StatusOK, N{"id": c.Param("id")}) })
+
+	conn, client := newTestConn(t, e)
+	conn.SetIdentity("alice")
+	for _, r := range []ReqMessage{
+		{ID: "req-1", Method: GET, Path: "/users/7"},
+		{ID: "req-2", Method: GET, Path: "/missing"},
+	} {
+		req, _ := json.Marshal(r)
+		if err := client.WriteFrame(req); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+		readUntil(t, client, func(m ResMessage) bool { return m.ID == r.ID })
+	}
+	waitFor(t, func() bool { return len(out.lines()) == 2 })
+	return out.lines()
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	lines := accessLogLines(t, AccessLogCombined)
+	want := []*regexp.Regexp{
+		regexp.MustCompile(`^pipe alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /users/7" 200 [1-9]\d* [1-9]\d* \S+ req-1 /users/:id$`),
+		regexp.MustCompile(`^pipe alice \[[^]]+\] "GET /missing" 404 [1-9]\d* [1-9]\d* \S+ req-2 -$`),
+	}
+	for i, re := range want {
+		if !re.MatchString(lines[i]) {
+			t.Errorf("line %d = %q, want match for %s", i, lines[i], re)
+		}
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	lines := accessLogLines(t, AccessLogJSON)
+	want := []map[string]any{
+		{"request_id": "req-1", "identity": "alice", "method": "GET", "path": "/users/7", "route": "/users/:id", "status": float64(200), "remote_addr": "pipe"},
+		{"request_id": "req-2", "identity": "alice", "method": "GET", "path": "/missing", "status": float64(404)},
+	}
+	for i, fields := range want {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &got); err != nil {
+			t.Fatalf("line %d = %q is not JSON: %v", i, lines[i], err)
+		}
+		for k, v := range fields {
+			if got[k] != v {
+				t.Errorf("line %d: %s = %v, want %v", i, k, got[k], v)
+			}
+		}
+		if _, ok := got["latency_ms"].(float64); !ok {
+			t.Errorf("line %d: latency_ms = %v, want milliseconds", i, got["latency_ms"])
+		}
+		if _, ok := got["route"]; i == 1 && ok {
+			t.Errorf("line %d: route = %v, want omitted for unmatched request", i, got["route"])
+		}
+	}
+}
+
+func TestAccessLogCustomTemplate(t *testing.T) {
+	lines := accessLogLines(t, `{{.RequestID}} {{.Method}} {{.Route | dash}} {{.Status}} {{.Identity}}`)
+	want := []string{"req-1 GET /users/:id 200 alice", "req-2 GET - 404 alice"}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("invalid template did not panic")
+		}
+	}()
+	newAccessLogger(AccessLogConfig{Format: "{{.Method"})
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	steps := []struct {
+		write              string
+		cur, first, second string
+	}{
+		{"aaaaa\n", "aaaaa\n", "<missing>", "<missing>"},
+		// 正好达到上限时不轮转
+		{"bbb\n", "aaaaa\nbbb\n", "<missing>", "<missing>"},
+		{"ccccc\n", "ccccc\n", "aaaaa\nbbb\n", "<missing>"},
+		{"ddddd\n", "ddddd\n", "ccccc\n", "aaaaa\nbbb\n"},
+		// 超过保留数量的历史文件被删除
+		{"eeeee\n", "eeeee\n", "ddddd\n", "ccccc\n"},
+		// 单次写入超过上限时仍然完整写入
+		{"ffffffffffff\n", "ffffffffffff\n", "eeeee\n", "ddddd\n"},
+	}
+	for i, step := range steps {
+		if n, err := f.Write([]byte(step.write)); err != nil || n != len(step.write) {
+			t.Fatalf("step %d: Write = %d, %v", i, n, err)
+		}
+		got := []string{read(path), read(path + ".1"), read(path + ".2")}
+		want := []string{step.cur, step.first, step.second}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Fatalf("step %d: file %d = %q, want %q", i, j, got[j], want[j])
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("backup beyond maxBackups exists: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("Write after Close = %v, want os.ErrClosed", err)
+	}
+
+	// 重新打开时沿用已有文件的大小，没有历史文件时轮转直接丢弃旧内容
+	f, err = NewRotatingFile(path, 16, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("gggg\n"))
+	if got := read(path); got != "gggg\n" {
+		t.Fatalf("after reopen = %q, want old content discarded", got)
+	}
+}
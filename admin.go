@@ -0,0 +1,264 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// adminConnection 管理接口中的一个连接
+type adminConnection struct {
+	ID          string    `json:"id"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Identity    string    `json:"identity,omitempty"`
+	SessionID   string    `json:"session_id,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Rooms       []string  `json:"rooms,omitempty"`
+	QueueDepth  int       `json:"queue_depth"`
+	QueueSize   int       `json:"queue_size"`
+}
+
+// adminRoom 管理接口中的一个房间，主题订阅也以房间的形式列出
+type adminRoom struct {
+	Name    string   `json:"name"`
+	Topic   string   `json:"topic,omitempty"`
+	Members []string `json:"members"`
+}
+
+// adminRuntime 管理接口中的运行时信息
+type adminRuntime struct {
+	NodeID     string    `json:"node_id"`
+	StartedAt  time.Time `json:"started_at"`
+	Uptime     string    `json:"uptime"`
+	GoVersion  string    `json:"go_version"`
+	Goroutines int       `json:"goroutines"`
+	NumCPU     int       `json:"num_cpu"`
+	HeapAlloc  uint64    `json:"heap_alloc"`
+	Sessions   int       `json:"sessions"`
+}
+
+// adminConnections 返回本节点的所有连接及其发送队列深度
+func (e *Engine) adminConnections() []adminConnection {
+	conns := e.Connections()
+	list := make([]adminConnection, 0, len(conns))
+	for _, conn := range conns {
+		list = append(list, adminConnection{
+			ID:          conn.id,
+			RemoteAddr:  conn.RemoteAddr(),
+			Identity:    conn.Identity(),
+			SessionID:   conn.SessionID(),
+			ConnectedAt: conn.ConnectedAt(),
+			Rooms:       conn.Rooms(),
+			QueueDepth:  len(conn.send),
+			QueueSize:   cap(conn.send),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ConnectedAt.Before(list[j].ConnectedAt) })
+	return list
+}
+
+// adminRooms 返回本节点的所有房间和主题订阅
+func (e *Engine) adminRooms() []adminRoom {
+	rooms := e.Rooms()
+	list := make([]adminRoom, 0, len(rooms))
+	for _, room := range rooms {
+		members := e.RoomMembers(room)
+		ids := make([]string, 0, len(members))
+		for _, conn := range members {
+			ids = append(ids, conn.id)
+		}
+		sort.Strings(ids)
+		r := adminRoom{Name: room, Members: ids}
+		if isTopicRoom(room) {
+			r.Topic = strings.TrimPrefix(room, topicRoomPrefix)
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+// adminRuntime 返回本节点的运行时信息
+func (e *Engine) adminRuntime() adminRuntime {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	e.sessionsMu.Lock()
+	sessions := len(e.sessions)
+	e.sessionsMu.Unlock()
+	return adminRuntime{
+		NodeID:     e.nodeID,
+		StartedAt:  e.startedAt,
+		Uptime:     time.Since(e.startedAt).Round(time.Second).String(),
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		NumCPU:     runtime.NumCPU(),
+		HeapAlloc:  mem.HeapAlloc,
+		Sessions:   sessions,
+	}
+}
+
+// adminConfig 返回可以序列化的配置
+// 函数、接口和io.Writer等字段只输出是否已设置
+func (e *Engine) adminConfig() map[string]any {
+	return configValue(reflect.ValueOf(e.config)).(map[string]any)
+}
+
+// configValue 把配置转换为可以序列化为JSON的值
+func configValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				m[f.Name] = configValue(v.Field(i))
+			}
+		}
+		return m
+	case reflect.Func, reflect.Interface, reflect.Chan, reflect.Pointer:
+		return !v.IsNil()
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Func {
+			return v.Len()
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = configValue(v.Index(i))
+		}
+		return list
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = configValue(iter.Value())
+		}
+		return m
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return v.Interface()
+}
+
+// adminSection 返回管理接口中某一部分的内容，section为空时返回全部
+func (e *Engine) adminSection(section string) (any, bool) {
+	switch section {
+	case "":
+		return N{
+			"routes":      e.Routes(),
+			"connections": e.adminConnections(),
+			"rooms":       e.adminRooms(),
+			"runtime":     e.adminRuntime(),
+			"config":      e.adminConfig(),
+		}, true
+	case "routes":
+		return e.Routes(), true
+	case "connections":
+		return e.adminConnections(), true
+	case "rooms":
+		return e.adminRooms(), true
+	case "runtime":
+		return e.adminRuntime(), true
+	case "config":
+		return e.adminConfig(), true
+	}
+	return nil, false
+}
+
+// AdminHandler 返回提供管理接口的http.Handler，由 Run 挂载在 AdminConfig.Path 下
+// 未配置 AdminConfig.Authorize 时拒绝所有请求
+//
+//	GET  <path>                 全部信息
+//	GET  <path>/<section>       routes、connections、rooms、runtime 或 config
+//	POST <path>/kick?id=&reason= 断开指定连接
+func (e *Engine) AdminHandler() http.Handler {
+	prefix := strings.TrimSuffix(e.config.AdminConfig.Path, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize := e.config.AdminConfig.Authorize; authorize == nil || !authorize(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		section := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+		if section == "kick" {
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := e.adminKick(r.FormValue("id"), r.FormValue("reason")); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			writeAdminJSON(w, N{"kicked": r.FormValue("id")})
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, ok := e.adminSection(section)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeAdminJSON(w, data)
+	})
+}
+
+// writeAdminJSON 以JSON输出管理接口的响应
+func writeAdminJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(data)
+}
+
+// adminKick 通过管理接口断开连接
+func (e *Engine) adminKick(id, reason string) error {
+	if reason == "" {
+		reason = "kicked by admin"
+	}
+	if err := e.Kick(id, reason); err != nil {
+		return err
+	}
+	e.log(LogEngine).Info("connection kicked by admin", "conn", id, "reason", reason)
+	return nil
+}
+
+// registerAdminRoutes 以Nexus路由提供管理接口，AdminConfig.Handlers 在管理路由之前执行，负责鉴权
+// 管理路由可以断开任意连接，未配置 AdminConfig.Handlers 时直接panic，避免无鉴权地暴露
+//
+//	GET  <path>             全部信息
+//	GET  <path>/:section    routes、connections、rooms、runtime 或 config
+//	POST <path>/kick        请求体 {"id": "...", "reason": "..."}
+func (e *Engine) registerAdminRoutes() {
+	assert1(len(e.config.AdminConfig.Handlers) > 0, "AdminConfig.Routes requires AdminConfig.Handlers to authorize admin requests")
+	group := e.Group(e.config.AdminConfig.Path, e.config.AdminConfig.Handlers...)
+	show := func(c *Context) {
+		data, ok := e.adminSection(c.Param("section"))
+		if !ok {
+			DefaultHandler404Handler(c)
+			return
+		}
+		c.Response = &ResMessage{ID: c.Request.ID, Header: c.Header, Status: StatusOK, Body: data}
+		c.Exit()
+	}
+	group.GET("", show)
+	group.GET("/:section", show)
+	group.POST("/kick", func(c *Context) {
+		id, _ := bodyString(c.Request.Body, "id")
+		reason, _ := bodyString(c.Request.Body, "reason")
+		if id == "" {
+			c.JSON(StatusBadRequest, N{"error": "id is required"})
+			return
+		}
+		if err := e.adminKick(id, reason); err != nil {
+			c.JSON(StatusNotFound, N{"error": err.Error()})
+			return
+		}
+		c.JSON(StatusOK, N{"kicked": id})
+	})
+}
@@ -0,0 +1,73 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminHandlerAuthorize(t *testing.T) {
+	tests := []struct {
+		name      string
+		authorize func(*http.Request) bool
+		want      int
+	}{
+		{"no authorizer", nil, http.StatusForbidden},
+		{"rejected", func(*http.Request) bool { return false }, http.StatusForbidden},
+		{"allowed", func(*http.Request) bool { return true }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.AdminConfig.Authorize = tt.authorize
+		e := NewWithConfig(cfg)
+		rec := httptest.NewRecorder()
+		e.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/nexus/runtime", nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAdminRoutesRequireHandlers(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("AdminConfig.Routes without Handlers did not panic")
+		}
+	}()
+	cfg := DefaultConfig()
+	cfg.AdminConfig.Routes = true
+	NewWithConfig(cfg)
+}
+
+func TestAdminRoutesAuthorize(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AdminConfig.Routes = true
+	cfg.AdminConfig.Handlers = HandlerFuncList{func(c *Context) {
+		if c.Request.Header["token"] != "secret" {
+			c.JSON(StatusForbidden, N{"error": "forbidden"})
+			c.Exit()
+		}
+	}}
+	e := NewWithConfig(cfg)
+	_, client := newTestConn(t, e)
+
+	tests := []struct {
+		id    string
+		token string
+		want  status
+	}{
+		{"anonymous", "", StatusForbidden},
+		{"authorized", "secret", StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := json.Marshal(ReqMessage{ID: tt.id, Method: GET, Path: "/debug/nexus/runtime", Header: header{"token": tt.token}})
+		if err := client.WriteFrame(req); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+		res, _ := readUntil(t, client, func(m ResMessage) bool { return m.ID == tt.id })
+		if res.Status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.id, res.Status, tt.want)
+		}
+	}
+}
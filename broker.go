@@ -0,0 +1,201 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Broker 是跨节点的发布/订阅消息代理
+// Engine通过Broker把广播、房间消息、定向消息和 Kick 同步到其他节点
+// 在线状态的变化会作为房间消息推送到所有节点，但 Presence 的查询只包含本节点的连接
+type Broker interface {
+	// Publish 向频道发布消息
+	Publish(channel string, data []byte) error
+	// Subscribe 订阅频道，返回取消订阅的函数
+	Subscribe(channel string, handler func(data []byte)) (func(), error)
+	// Close 关闭代理
+	Close() error
+}
+
+// 集群频道名称，实际使用时会加上 ClusterConfig.ChannelPrefix 前缀
+const (
+	channelBroadcast = "broadcast"
+	channelRoom      = "room"
+	channelTopic     = "topic"
+	channelConn      = "conn"
+	channelIdentity  = "identity"
+	channelKick      = "kick"
+	channelDelivered = "delivered"
+)
+
+// brokerEnvelope 表示经由Broker转发的消息
+type brokerEnvelope struct {
+	// Origin 发布消息的节点ID，节点会忽略自己发布的消息
+	Origin string `json:"origin"`
+	// Target 房间名、主题、连接ID或身份，广播时为空
+	Target string `json:"target,omitempty"`
+	// Data 原始消息
+	Data []byte `json:"data"`
+	// Reply 需要确认投递时的消息ID，接收的节点投递成功后在 delivered 频道回复该ID
+	Reply string `json:"reply,omitempty"`
+}
+
+// startCluster 订阅集群频道，未配置Broker时不做任何事
+func (e *Engine) startCluster() {
+	broker := e.config.ClusterConfig.Broker
+	if broker == nil {
+		return
+	}
+
+	handlers := map[string]func(brokerEnvelope){
+		channelBroadcast: func(env brokerEnvelope) {
+			e.broadcastLocal(env.Data)
+		},
+		channelRoom: func(env brokerEnvelope) {
+			e.broadcastToLocalRoom(env.Target, env.Data)
+		},
+		channelTopic: func(env brokerEnvelope) {
+			e.publishToLocalTopic(env.Target, env.Data)
+		},
+		channelConn: func(env brokerEnvelope) {
+			if conn, ok := e.Connection(env.Target); ok {
+				conn.Send(env.Data)
+			}
+		},
+		channelIdentity: func(env brokerEnvelope) {
+			if e.sendToLocalIdentity(env.Target, env.Data) > 0 && env.Reply != "" {
+				e.publishEnvelope(channelDelivered, brokerEnvelope{Target: env.Origin, Data: []byte(env.Reply)})
+			}
+		},
+		channelDelivered: func(env brokerEnvelope) {
+			if env.Target == e.nodeID {
+				e.confirmDelivery(string(env.Data))
+			}
+		},
+		channelKick: func(env brokerEnvelope) {
+			if conn, ok := e.Connection(env.Target); ok {
+				conn.Close(ClosePolicyViolation, string(env.Data))
+			}
+		},
+	}
+
+	for name, handle := range handlers {
+		handle := handle
+		unsubscribe, err := broker.Subscribe(e.clusterChannel(name), func(data []byte) {
+			var env brokerEnvelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				e.log(LogCluster).Warn("invalid broker message", "channel", name, "error", err)
+				return
+			}
+			if env.Origin == e.nodeID {
+				return
+			}
+			handle(env)
+		})
+		if err != nil {
+			e.log(LogCluster).Error("broker subscribe failed", "channel", name, "error", err)
+			continue
+		}
+		e.clusterUnsubscribe = append(e.clusterUnsubscribe, unsubscribe)
+	}
+}
+
+// stopCluster 取消所有集群频道的订阅
+func (e *Engine) stopCluster() {
+	for _, unsubscribe := range e.clusterUnsubscribe {
+		unsubscribe()
+	}
+	e.clusterUnsubscribe = nil
+}
+
+// clusterChannel 返回带前缀的频道名称
+func (e *Engine) clusterChannel(name string) string {
+	return e.config.ClusterConfig.ChannelPrefix + name
+}
+
+// publish 把消息发布给其他节点，未配置Broker时直接返回
+func (e *Engine) publish(name, target string, data []byte) error {
+	return e.publishEnvelope(name, brokerEnvelope{Target: target, Data: data})
+}
+
+// publishEnvelope 以本节点为来源发布消息，未配置Broker时直接返回
+func (e *Engine) publishEnvelope(name string, env brokerEnvelope) error {
+	broker := e.config.ClusterConfig.Broker
+	if broker == nil {
+		return nil
+	}
+	env.Origin = e.nodeID
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	if err = broker.Publish(e.clusterChannel(name), data); err != nil {
+		e.log(LogCluster).Warn("broker publish failed", "channel", name, "error", err)
+		return err
+	}
+	return nil
+}
+
+// NodeID 返回当前节点在集群中的唯一标识
+func (e *Engine) NodeID() string {
+	return e.nodeID
+}
+
+// MemoryBroker 进程内的Broker实现，多个Engine共享同一实例即可组成集群
+type MemoryBroker struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[string]map[int]func([]byte)
+}
+
+// NewMemoryBroker 创建进程内Broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		subs: make(map[string]map[int]func([]byte)),
+	}
+}
+
+// Publish 同步调用频道的所有订阅者
+func (b *MemoryBroker) Publish(channel string, data []byte) error {
+	b.mu.RLock()
+	handlers := make([]func([]byte), 0, len(b.subs[channel]))
+	for _, handler := range b.subs[channel] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+// Subscribe 订阅频道
+func (b *MemoryBroker) Subscribe(channel string, handler func([]byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[int]func([]byte))
+	}
+	b.subs[channel][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[channel], id)
+		if len(b.subs[channel]) == 0 {
+			delete(b.subs, channel)
+		}
+	}, nil
+}
+
+// Close 清空所有订阅
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = make(map[string]map[int]func([]byte))
+	return nil
+}
@@ -0,0 +1,387 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// tcpBrokerFrame 是TCP代理协议的帧，以换行分隔的JSON传输
+type tcpBrokerFrame struct {
+	// Op 操作类型：sub、unsub、pub 由客户端发送，msg 由服务端发送
+	Op      string `json:"op"`
+	Channel string `json:"channel"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+// ErrBrokerClosed 代理已关闭
+var ErrBrokerClosed = errors.New("broker closed")
+
+// TCPBrokerServer 是TCP代理的中心节点，把发布的消息转发给所有订阅了该频道的客户端
+type TCPBrokerServer struct {
+	mu       sync.Mutex
+	listener net.Listener
+	clients  map[*tcpBrokerPeer]struct{}
+	closed   bool
+}
+
+// tcpBrokerPeer 表示连接到代理服务端的一个客户端
+type tcpBrokerPeer struct {
+	conn     net.Conn
+	encoder  *json.Encoder
+	writeMu  sync.Mutex
+	channels map[string]struct{}
+}
+
+// NewTCPBrokerServer 创建TCP代理服务端
+func NewTCPBrokerServer() *TCPBrokerServer {
+	return &TCPBrokerServer{
+		clients: make(map[*tcpBrokerPeer]struct{}),
+	}
+}
+
+// ListenAndServe 监听指定地址并处理客户端连接
+func (s *TCPBrokerServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在指定的监听器上处理客户端连接，直到 Close 被调用
+func (s *TCPBrokerServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrBrokerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.servePeer(conn)
+	}
+}
+
+// Addr 返回监听地址，尚未开始监听时返回nil
+func (s *TCPBrokerServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close 停止监听并断开所有客户端
+func (s *TCPBrokerServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for peer := range s.clients {
+		peer.conn.Close()
+	}
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+// servePeer 读取客户端发来的帧
+func (s *TCPBrokerServer) servePeer(conn net.Conn) {
+	peer := &tcpBrokerPeer{
+		conn:     conn,
+		encoder:  json.NewEncoder(conn),
+		channels: make(map[string]struct{}),
+	}
+
+	s.mu.Lock()
+	s.clients[peer] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, peer)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var frame tcpBrokerFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return
+		}
+
+		switch frame.Op {
+		case "sub":
+			s.mu.Lock()
+			peer.channels[frame.Channel] = struct{}{}
+			s.mu.Unlock()
+		case "unsub":
+			s.mu.Lock()
+			delete(peer.channels, frame.Channel)
+			s.mu.Unlock()
+		case "pub":
+			s.forward(frame.Channel, frame.Data)
+		}
+	}
+}
+
+// forward 把消息转发给订阅了频道的所有客户端
+func (s *TCPBrokerServer) forward(channel string, data []byte) {
+	s.mu.Lock()
+	var targets []*tcpBrokerPeer
+	for peer := range s.clients {
+		if _, ok := peer.channels[channel]; ok {
+			targets = append(targets, peer)
+		}
+	}
+	s.mu.Unlock()
+
+	frame := tcpBrokerFrame{Op: "msg", Channel: channel, Data: data}
+	for _, peer := range targets {
+		peer.writeMu.Lock()
+		err := peer.encoder.Encode(frame)
+		peer.writeMu.Unlock()
+		if err != nil {
+			peer.conn.Close()
+		}
+	}
+}
+
+// TCPBroker 重连等待时间的范围，每次失败后翻倍
+const (
+	tcpBrokerMinBackoff = 100 * time.Millisecond
+	tcpBrokerMaxBackoff = 10 * time.Second
+)
+
+// ErrBrokerDisconnected 与代理服务端的连接已断开，正在重连
+var ErrBrokerDisconnected = errors.New("broker disconnected")
+
+// TCPBroker 是连接到 TCPBrokerServer 的Broker实现
+// 与服务端的连接断开后会按指数退避自动重连，并重新订阅所有频道
+// 断开期间 Publish 返回 ErrBrokerDisconnected，消息不会缓存
+type TCPBroker struct {
+	addr    string
+	writeMu sync.Mutex
+	conn    net.Conn
+	encoder *json.Encoder
+	mu      sync.RWMutex
+	nextID  int
+	subs    map[string]map[int]func([]byte)
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// DialTCPBroker 连接到TCP代理服务端
+func DialTCPBroker(addr string) (*TCPBroker, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	b := &TCPBroker{
+		addr:    addr,
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		subs:    make(map[string]map[int]func([]byte)),
+		closed:  make(chan struct{}),
+	}
+	go b.readLoop(conn)
+	return b, nil
+}
+
+// Publish 发布消息，服务端会转发给包括自身在内的所有订阅者
+func (b *TCPBroker) Publish(channel string, data []byte) error {
+	return b.write(tcpBrokerFrame{Op: "pub", Channel: channel, Data: data})
+}
+
+// Subscribe 订阅频道，同一频道的首个订阅者会向服务端发送订阅请求
+// 连接断开期间订阅的频道会在重连后发送给服务端
+func (b *TCPBroker) Subscribe(channel string, handler func([]byte)) (func(), error) {
+	select {
+	case <-b.closed:
+		return nil, ErrBrokerClosed
+	default:
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	first := b.subs[channel] == nil
+	if first {
+		b.subs[channel] = make(map[int]func([]byte))
+	}
+	b.subs[channel][id] = handler
+	b.mu.Unlock()
+
+	if first {
+		// 写入失败说明连接已断开，重连时读取的订阅中已包含该频道
+		b.write(tcpBrokerFrame{Op: "sub", Channel: channel})
+	}
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subs[channel], id)
+		last := len(b.subs[channel]) == 0
+		if last {
+			delete(b.subs, channel)
+		}
+		b.mu.Unlock()
+
+		if last {
+			b.write(tcpBrokerFrame{Op: "unsub", Channel: channel})
+		}
+	}, nil
+}
+
+// Close 断开与服务端的连接并停止重连
+func (b *TCPBroker) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.closed)
+		b.writeMu.Lock()
+		if b.conn != nil {
+			err = b.conn.Close()
+		}
+		b.writeMu.Unlock()
+	})
+	return err
+}
+
+// Done 返回在 Close 被调用后关闭的通道
+func (b *TCPBroker) Done() <-chan struct{} {
+	return b.closed
+}
+
+// Connected 判断当前是否与服务端保持连接
+func (b *TCPBroker) Connected() bool {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	return b.conn != nil
+}
+
+// write 发送一帧到服务端
+func (b *TCPBroker) write(frame tcpBrokerFrame) error {
+	select {
+	case <-b.closed:
+		return ErrBrokerClosed
+	default:
+	}
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if b.conn == nil {
+		return ErrBrokerDisconnected
+	}
+	return b.encoder.Encode(frame)
+}
+
+// readLoop 读取服务端转发的消息并分发给订阅者，连接断开后重连
+func (b *TCPBroker) readLoop(conn net.Conn) {
+	for {
+		b.read(conn)
+
+		b.writeMu.Lock()
+		if b.conn == conn {
+			b.conn = nil
+			b.encoder = nil
+		}
+		b.writeMu.Unlock()
+		conn.Close()
+
+		if conn = b.reconnect(); conn == nil {
+			return
+		}
+	}
+}
+
+// read 读取一个连接上的消息直到连接断开
+func (b *TCPBroker) read(conn net.Conn) {
+	decoder := json.NewDecoder(conn)
+	for {
+		var frame tcpBrokerFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return
+		}
+		if frame.Op != "msg" {
+			continue
+		}
+
+		b.mu.RLock()
+		handlers := make([]func([]byte), 0, len(b.subs[frame.Channel]))
+		for _, handler := range b.subs[frame.Channel] {
+			handlers = append(handlers, handler)
+		}
+		b.mu.RUnlock()
+
+		for _, handler := range handlers {
+			handler(frame.Data)
+		}
+	}
+}
+
+// reconnect 按指数退避重连并重新订阅所有频道，Close 被调用后返回nil
+func (b *TCPBroker) reconnect() net.Conn {
+	backoff := tcpBrokerMinBackoff
+	for {
+		select {
+		case <-b.closed:
+			return nil
+		case <-time.After(backoff):
+		}
+
+		conn, err := net.Dial("tcp", b.addr)
+		if err != nil {
+			backoff = min(backoff*2, tcpBrokerMaxBackoff)
+			continue
+		}
+
+		// 持有 writeMu 时读取订阅并安装新连接，其间新增的订阅在写入时已能看到新连接
+		b.writeMu.Lock()
+		select {
+		case <-b.closed:
+			b.writeMu.Unlock()
+			conn.Close()
+			return nil
+		default:
+		}
+		b.mu.RLock()
+		channels := make([]string, 0, len(b.subs))
+		for channel := range b.subs {
+			channels = append(channels, channel)
+		}
+		b.mu.RUnlock()
+		encoder := json.NewEncoder(conn)
+		var subErr error
+		for _, channel := range channels {
+			if subErr = encoder.Encode(tcpBrokerFrame{Op: "sub", Channel: channel}); subErr != nil {
+				break
+			}
+		}
+		if subErr == nil {
+			b.conn, b.encoder = conn, encoder
+		}
+		b.writeMu.Unlock()
+
+		if subErr != nil {
+			conn.Close()
+			backoff = min(backoff*2, tcpBrokerMaxBackoff)
+			continue
+		}
+		return conn
+	}
+}
@@ -0,0 +1,253 @@
+package Nexus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestConn 通过管道创建并注册一个连接，返回服务端连接和客户端一端
+func newTestConn(t *testing.T, e *Engine) (*Connection, FrameConn) {
+	t.Helper()
+	client, server := NewPipe()
+	conn, err := e.ServeConn(server)
+	if err != nil {
+		t.Fatalf("ServeConn: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	waitFor(t, func() bool {
+		_, ok := e.Connection(conn.ID())
+		return ok
+	})
+	return conn, client
+}
+
+// readTestFrame 在超时时间内读取一帧
+func readTestFrame(t *testing.T, fc FrameConn) ([]byte, error) {
+	t.Helper()
+	fc.SetReadDeadline(time.Now().Add(time.Second))
+	return fc.ReadFrame()
+}
+
+// waitFor 等待条件成立，超时则测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newClusterEngine 创建使用指定Broker的Engine
+func newClusterEngine(broker Broker) *Engine {
+	cfg := DefaultConfig()
+	cfg.ClusterConfig.Broker = broker
+	return NewWithConfig(cfg)
+}
+
+func TestMemoryBrokerTwoEngines(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(from *Engine, to *Connection)
+	}{
+		{"broadcast", func(from *Engine, to *Connection) { from.Broadcast([]byte("hello")) }},
+		{"room", func(from *Engine, to *Connection) {
+			to.Join("lobby")
+			from.BroadcastToRoom("lobby", []byte("hello"))
+		}},
+		{"pattern topic", func(from *Engine, to *Connection) {
+			to.Join(topicRoom("/rooms/:id"))
+			from.Publish("/rooms/7", "hello")
+		}},
+		{"connection", func(from *Engine, to *Connection) { from.SendTo(to.ID(), []byte("hello")) }},
+		{"identity", func(from *Engine, to *Connection) {
+			to.SetIdentity("alice")
+			from.SendToIdentity("alice", []byte("hello"))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := NewMemoryBroker()
+			a, b := newClusterEngine(broker), newClusterEngine(broker)
+			defer a.stopCluster()
+			defer b.stopCluster()
+
+			conn, client := newTestConn(t, b)
+			tt.send(a, conn)
+			got, err := readTestFrame(t, client)
+			if err != nil || !bytes.Contains(got, []byte("hello")) {
+				t.Fatalf("ReadFrame = %q, %v, want message from other node", got, err)
+			}
+		})
+	}
+}
+
+func TestKickForwardedToOwningNode(t *testing.T) {
+	broker := NewMemoryBroker()
+	a, b := newClusterEngine(broker), newClusterEngine(broker)
+	defer a.stopCluster()
+	defer b.stopCluster()
+
+	conn, client := newTestConn(t, b)
+	if err := a.Kick(conn.ID(), "bye"); err != nil {
+		t.Fatalf("Kick: %v", err)
+	}
+	var ce *CloseError
+	if _, err := readTestFrame(t, client); !errors.As(err, &ce) || ce.Reason != "bye" {
+		t.Fatalf("ReadFrame error = %v, want close with reason", err)
+	}
+
+	if err := New().Kick("missing", "bye"); !errors.Is(err, ErrConnectionNotFound) {
+		t.Fatalf("Kick without broker = %v, want ErrConnectionNotFound", err)
+	}
+}
+
+// startTCPBrokerServer 在指定地址上启动代理服务端，返回实际监听的地址
+func startTCPBrokerServer(t *testing.T, addr string) (*TCPBrokerServer, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server := NewTCPBrokerServer()
+	go server.Serve(ln)
+	return server, ln.Addr().String()
+}
+
+func TestTCPBrokerPublishSubscribe(t *testing.T) {
+	server, addr := startTCPBrokerServer(t, "127.0.0.1:0")
+	defer server.Close()
+
+	a, err := DialTCPBroker(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := DialTCPBroker(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	x, y := newClusterEngine(a), newClusterEngine(b)
+	defer x.stopCluster()
+	defer y.stopCluster()
+	// 等待订阅请求到达服务端
+	time.Sleep(50 * time.Millisecond)
+
+	_, client := newTestConn(t, y)
+	x.Broadcast([]byte("hello"))
+	got, err := readTestFrame(t, client)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("ReadFrame = %q, %v, want broadcast from other node", got, err)
+	}
+}
+
+func TestTCPBrokerReconnect(t *testing.T) {
+	server, addr := startTCPBrokerServer(t, "127.0.0.1:0")
+
+	b, err := DialTCPBroker(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	received := make(chan string, 1)
+	if _, err := b.Subscribe("ch", func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务端重启后应自动重连并重新订阅
+	server.Close()
+	waitFor(t, func() bool { return !b.Connected() })
+	if err := b.Publish("ch", []byte("lost")); !errors.Is(err, ErrBrokerDisconnected) {
+		t.Fatalf("Publish while disconnected = %v, want ErrBrokerDisconnected", err)
+	}
+
+	server, _ = startTCPBrokerServer(t, addr)
+	defer server.Close()
+	waitFor(t, b.Connected)
+	// 重新订阅的请求与发布在同一连接上按序到达
+	if err := b.Publish("ch", []byte("again")); err != nil {
+		t.Fatalf("Publish after reconnect: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != "again" {
+			t.Fatalf("received %q, want again", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message after reconnect")
+	}
+
+	select {
+	case <-b.Done():
+		t.Fatal("Done closed before Close")
+	default:
+	}
+	b.Close()
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after Close")
+	}
+}
+
+func TestTCPBrokerSubscribeDuringReconnect(t *testing.T) {
+	server, addr := startTCPBrokerServer(t, "127.0.0.1:0")
+	b, err := DialTCPBroker(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	server.Close()
+	waitFor(t, func() bool { return !b.Connected() })
+	server, _ = startTCPBrokerServer(t, addr)
+	defer server.Close()
+
+	// 在重连前后持续订阅新频道，每个频道都必须在服务端生效
+	received := make(chan string, 4096)
+	var channels []string
+	var reconnected time.Time
+	for i := 0; reconnected.IsZero() || time.Since(reconnected) < 20*time.Millisecond; i++ {
+		channel := fmt.Sprintf("ch%d", i)
+		channels = append(channels, channel)
+		b.Subscribe(channel, func(data []byte) {
+			select {
+			case received <- channel:
+			default:
+			}
+		})
+		if reconnected.IsZero() && b.Connected() {
+			reconnected = time.Now()
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+	got := make(map[string]bool)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(got) < len(channels) {
+		if time.Now().After(deadline) {
+			t.Fatalf("received on %d of %d channels subscribed around the reconnect", len(got), len(channels))
+		}
+		for _, channel := range channels {
+			if !got[channel] {
+				b.Publish(channel, []byte("x"))
+			}
+		}
+		// 收取这一轮发布的消息，之后对仍未收到的频道再次发布
+		for quiet := false; !quiet; {
+			select {
+			case channel := <-received:
+				got[channel] = true
+			case <-time.After(50 * time.Millisecond):
+				quiet = true
+			}
+		}
+	}
+}
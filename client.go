@@ -1,10 +1,15 @@
 package Nexus
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
 	"net/url"
 	"sync"
 	"time"
@@ -18,12 +23,51 @@ type ClientConfig struct {
 	RequestTimeout time.Duration
 	// 自动重连
 	AutoReconnect bool
-	// 重连间隔
+	// 首次重连前的等待时间
 	ReconnectInterval time.Duration
-	// 最大重连次数
+	// 重连等待时间的上限
+	MaxReconnectInterval time.Duration
+	// 每次重连失败后等待时间的增长倍数，不大于1时使用固定间隔
+	ReconnectMultiplier float64
+	// 等待时间的随机抖动比例，取值0~1，避免大量客户端同时重连
+	ReconnectJitter float64
+	// 最大重连次数，不大于0表示无限重试
 	MaxReconnectAttempts int
-	// 调试日志
+	// 连接状态变化时的回调，进入 StateFailed 时err为最后一次失败的原因
+	OnStateChange func(state ClientState, err error)
+	// 重连后恢复订阅失败时的回调，被服务端拒绝的订阅会被移除
+	OnResubscribeError func(topic string, err error)
+	// 断线期间最多排队等待发送的请求数，0表示不排队，仅在启用自动重连时生效
+	QueueSize int
+	// 心跳间隔，0表示不发送心跳
+	HeartbeatInterval time.Duration
+	// 心跳超时，超过该时间未收到任何消息或心跳响应时认为连接已断开
+	HeartbeatTimeout time.Duration
+	// 调试日志，未配置 Logger 时调试模式输出所有日志，否则输出警告和错误
 	Debug bool
+	// 自定义日志记录器，为nil时输出到标准错误
+	Logger *slog.Logger
+	// 请求跨度的导出器，为nil时仍会在请求头中注入traceparent但不导出跨度
+	SpanExporter SpanExporter
+	// 传输方式，为nil时使用根据下面的拨号选项创建的WebSocketTransport
+	Transport Transport
+
+	// 握手请求附带的HTTP头
+	Header http.Header
+	// 每次连接和重连前调用，返回的HTTP头覆盖 Header 中的同名字段，可用于刷新令牌
+	HeaderFunc func() (http.Header, error)
+	// TLS配置，可用于信任私有CA或提供客户端证书
+	TLSConfig *tls.Config
+	// 代理函数，为nil时使用环境变量中的代理设置
+	Proxy func(*http.Request) (*url.URL, error)
+	// 握手超时时间，0表示使用默认的45秒
+	HandshakeTimeout time.Duration
+	// 请求的WebSocket子协议
+	Subprotocols []string
+	// 是否协商消息压缩
+	EnableCompression bool
+	// 自定义建立TCP连接的函数
+	NetDialContext func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 // DefaultClientConfig 返回默认客户端配置
@@ -32,14 +76,120 @@ func DefaultClientConfig() ClientConfig {
 		RequestTimeout:       10 * time.Second,
 		AutoReconnect:        true,
 		ReconnectInterval:    5 * time.Second,
+		MaxReconnectInterval: time.Minute,
+		ReconnectMultiplier:  2,
+		ReconnectJitter:      0.2,
 		MaxReconnectAttempts: 5,
+		HeartbeatInterval:    30 * time.Second,
+		HeartbeatTimeout:     60 * time.Second,
 		Debug:                false,
+		HandshakeTimeout:     defaultHandshakeTimeout,
+	}
+}
+
+// defaultHandshakeTimeout 未配置握手超时时使用的默认值
+const defaultHandshakeTimeout = 45 * time.Second
+
+// dialer 根据拨号选项创建WebSocket拨号器
+func (cfg ClientConfig) dialer() *websocket.Dialer {
+	proxy := cfg.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	// 零值的Dialer不限制握手时间，未配置时退回默认值
+	timeout := cfg.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = defaultHandshakeTimeout
+	}
+	return &websocket.Dialer{
+		Proxy:             proxy,
+		HandshakeTimeout:  timeout,
+		TLSClientConfig:   cfg.TLSConfig,
+		Subprotocols:      cfg.Subprotocols,
+		EnableCompression: cfg.EnableCompression,
+		NetDialContext:    cfg.NetDialContext,
+	}
+}
+
+// dialHeader 合并固定的HTTP头和 HeaderFunc 返回的HTTP头
+func (cfg ClientConfig) dialHeader() (http.Header, error) {
+	h := cfg.Header.Clone()
+	if cfg.HeaderFunc == nil {
+		return h, nil
+	}
+	extra, err := cfg.HeaderFunc()
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = make(http.Header, len(extra))
+	}
+	for k, v := range extra {
+		h[k] = v
+	}
+	return h, nil
+}
+
+// reconnectDelay 返回第attempt次重连前的等待时间
+func (cfg ClientConfig) reconnectDelay(attempt int) time.Duration {
+	d := float64(cfg.ReconnectInterval)
+	max := float64(cfg.MaxReconnectInterval)
+	if cfg.ReconnectMultiplier > 1 {
+		for i := 1; i < attempt; i++ {
+			d *= cfg.ReconnectMultiplier
+			if max > 0 && d >= max {
+				break
+			}
+		}
 	}
+	if max > 0 && d > max {
+		d = max
+	}
+	if cfg.ReconnectJitter > 0 {
+		jitter := cfg.ReconnectJitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= d * jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// ClientState 客户端的连接状态
+type ClientState int
+
+const (
+	// StateConnecting 正在建立连接
+	StateConnecting ClientState = iota
+	// StateConnected 连接已建立
+	StateConnected
+	// StateReconnecting 连接断开，等待下一次重连
+	StateReconnecting
+	// StateFailed 连接断开且不再自动重连，可调用 Reconnect 重新尝试
+	StateFailed
+	// StateClosed 客户端已关闭
+	StateClosed
+)
+
+func (s ClientState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateFailed:
+		return "failed"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
 }
 
 // Client 结构体表示一个 Nexus 客户端
 type Client struct {
-	conn            *websocket.Conn
+	conn            FrameConn
 	mu              sync.Mutex
 	pending         map[string]chan ResMessage
 	closeChan       chan struct{}
@@ -49,11 +199,27 @@ type Client struct {
 	path            string
 	config          ClientConfig
 	connected       bool
-	reconnectCount  int
-	subscriptions   map[string]HandlerFunc
+	restoring       bool
+	reconnectNow    bool
+	state           ClientState
+	stateMu         sync.Mutex
+	subscriptions   map[string]*subscription
 	subscriptionsMu sync.Mutex
+	session         string
+	delivered       map[string]struct{}
+	deliveredOrder  []string
+	queue           []ReqMessage
+	interceptors    []Interceptor
+	pushHandlers    HandlerFuncList
+	logger          *slog.Logger
 }
 
+// dedupWindow 客户端为可靠投递去重而记住的最近消息ID数量
+const dedupWindow = 1024
+
+// restoreKey 标记重连后恢复会话和订阅的请求，这些请求不排在断线队列之后
+type restoreKey struct{}
+
 // NewClient 函数用于创建并连接到 Nexus 服务
 func NewClient(scheme, host, path string) (*Client, error) {
 	return NewClientWithConfig(scheme, host, path, DefaultClientConfig())
@@ -61,95 +227,278 @@ func NewClient(scheme, host, path string) (*Client, error) {
 
 // NewClientWithConfig 使用指定配置创建并连接到 Nexus 服务
 func NewClientWithConfig(scheme, host, path string, config ClientConfig) (*Client, error) {
+	logConfig := LogConfig{Debug: config.Debug, Logger: config.Logger}
 	client := &Client{
 		scheme:        scheme,
 		host:          host,
 		path:          path,
 		pending:       make(map[string]chan ResMessage),
 		closeChan:     make(chan struct{}),
-		reconnectChan: make(chan struct{}),
+		reconnectChan: make(chan struct{}, 1),
 		config:        config,
-		subscriptions: make(map[string]HandlerFunc),
+		subscriptions: make(map[string]*subscription),
+		delivered:     make(map[string]struct{}),
+		logger:        logConfig.logger(logConfig.handler(), LogClient),
 	}
 
 	// 连接到服务器
-	if err := client.connect(); err != nil {
+	if err := client.connect(false); err != nil {
+		client.setState(StateFailed, err)
 		return nil, err
 	}
 
 	// 启动消息读取协程
 	go client.readMessages()
 
-	// 启动重连监控协程
-	if client.config.AutoReconnect {
-		go client.reconnectMonitor()
-	}
+	// 启动重连监控协程，未启用自动重连时只响应手动 Reconnect
+	go client.reconnectMonitor()
 
 	return client, nil
 }
 
 // connect 连接到WebSocket服务器
-func (c *Client) connect() error {
+// restoring 为true时连接建立后还需恢复会话和订阅，完成之前普通请求进入队列，状态也暂不变为 StateConnected
+func (c *Client) connect(restoring bool) error {
 	u := url.URL{Scheme: c.scheme, Host: c.host, Path: c.path}
 
-	if c.config.Debug {
-		log.Printf("[DEBUG] Connecting to %s", u.String())
+	c.logger.Debug("connecting", "url", u.String())
+
+	c.setState(StateConnecting, nil)
+
+	transport := c.config.Transport
+	if transport == nil {
+		transport = &WebSocketTransport{Dialer: c.config.dialer()}
+	}
+
+	h, err := c.config.dialHeader()
+	if err != nil {
+		return fmt.Errorf("failed to build handshake header: %w", err)
 	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, err := transport.Dial(u.String(), h)
 	if err != nil {
 		return fmt.Errorf("failed to connect to server: %w", err)
 	}
 
+	// 设置读取超时，收到心跳响应时延长
+	if c.config.HeartbeatTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(c.config.HeartbeatTimeout))
+		conn.SetPongHandler(func() {
+			conn.SetReadDeadline(time.Now().Add(c.config.HeartbeatTimeout))
+		})
+	}
+
 	c.mu.Lock()
 	c.conn = conn
 	c.connected = true
-	c.reconnectCount = 0
+	c.restoring = restoring
 	c.mu.Unlock()
+	if !restoring {
+		c.setState(StateConnected, nil)
+	}
 
-	if c.config.Debug {
-		log.Printf("[INFO] Connected to %s", u.String())
+	if c.config.HeartbeatInterval > 0 {
+		go c.heartbeat(conn)
 	}
 
+	c.logger.Info("connected", "url", u.String())
+
 	return nil
 }
 
-// reconnectMonitor 监控连接状态并在需要时重新连接
-func (c *Client) reconnectMonitor() {
+// heartbeat 定时向服务端发送心跳，连接被替换或客户端关闭时退出
+// 发送失败时触发重连，未收到响应则由读取超时触发重连
+func (c *Client) heartbeat(conn FrameConn) {
+	ticker := time.NewTicker(c.config.HeartbeatInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-c.closeChan:
 			return
-		case <-c.reconnectChan:
+		case <-ticker.C:
 			c.mu.Lock()
-			if c.connected || c.reconnectCount >= c.config.MaxReconnectAttempts {
+			if !c.connected || c.conn != conn {
 				c.mu.Unlock()
-				continue
+				return
 			}
-			c.reconnectCount++
-			attemptCount := c.reconnectCount
 			c.mu.Unlock()
 
-			if c.config.Debug {
-				log.Printf("[INFO] Reconnect attempt %d/%d", attemptCount, c.config.MaxReconnectAttempts)
+			if err := conn.Ping(); err != nil {
+				c.logger.Warn("heartbeat failed", "error", err)
+				// 关闭连接使读取协程退出并进入重连流程
+				conn.Close()
+				return
 			}
+		}
+	}
+}
+
+// reconnectMonitor 监控连接状态并在需要时重新连接
+func (c *Client) reconnectMonitor() {
+	for {
+		select {
+		case <-c.closeChan:
+			return
+		case <-c.reconnectChan:
+			c.reconnectLoop()
+		}
+	}
+}
 
-			// 等待重连间隔
-			time.Sleep(c.config.ReconnectInterval)
+// reconnectLoop 按退避策略重连，直到连接成功、超过最大重连次数或客户端关闭
+func (c *Client) reconnectLoop() {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		c.mu.Lock()
+		connected := c.connected
+		c.mu.Unlock()
+		if connected {
+			return
+		}
 
-			// 尝试重新连接
-			if err := c.connect(); err != nil {
-				if c.config.Debug {
-					log.Printf("[ERROR] Reconnect failed: %v", err)
-				}
-				// 触发下一次重连尝试
-				c.reconnectChan <- struct{}{}
-			} else {
-				// 连接成功，重新启动消息读取
-				go c.readMessages()
-			}
+		max := c.config.MaxReconnectAttempts
+		if max > 0 && attempt > max {
+			c.logger.Error("giving up reconnecting", "attempts", max, "error", lastErr)
+			c.setState(StateFailed, lastErr)
+			return
+		}
+		c.setState(StateReconnecting, nil)
+
+		// 手动重连的第一次尝试不等待
+		delay := c.config.reconnectDelay(attempt)
+		c.mu.Lock()
+		if c.reconnectNow {
+			c.reconnectNow = false
+			delay = 0
+		}
+		c.mu.Unlock()
+		c.logger.Info("reconnecting", "attempt", attempt, "delay", delay)
+
+		// 等待退避时间
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.closeChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// 记录断开前的会话，新连接建立后服务端会推送新的会话令牌
+		c.mu.Lock()
+		session := c.session
+		c.mu.Unlock()
+
+		// 尝试重新连接
+		if err := c.connect(true); err != nil {
+			c.logger.Warn("reconnect failed", "attempt", attempt, "error", err)
+			lastErr = err
+			continue
 		}
+
+		// 连接成功，重新启动消息读取，恢复会话和订阅后发送排队的请求
+		go c.readMessages()
+		go func() {
+			if session != "" {
+				c.resume(session)
+			}
+			c.resubscribe()
+			if c.flushQueue() {
+				c.setState(StateConnected, nil)
+			}
+		}()
+		return
+	}
+}
+
+// Reconnect 断开当前连接并立即开始重连，重连次数重新计算
+// 可用于在进入 StateFailed 后手动恢复连接
+func (c *Client) Reconnect() error {
+	select {
+	case <-c.closeChan:
+		return errors.New("客户端已关闭")
+	default:
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	c.connected = false
+	c.reconnectNow = true
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+	c.signalReconnect()
+	return nil
+}
+
+// signalReconnect 通知重连协程，已有待处理的通知时直接返回
+func (c *Client) signalReconnect() {
+	select {
+	case c.reconnectChan <- struct{}{}:
+	default:
+	}
+}
+
+// State 返回客户端当前的连接状态
+func (c *Client) State() ClientState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.state
+}
+
+// setState 更新连接状态，状态变化时调用 OnStateChange
+func (c *Client) setState(state ClientState, err error) {
+	c.stateMu.Lock()
+	// 每次拨号都通知 StateConnecting，其余状态只在变化时通知，关闭后不再变化
+	if c.state == StateClosed || (c.state == state && state != StateConnecting) {
+		c.stateMu.Unlock()
+		return
+	}
+	c.state = state
+	c.stateMu.Unlock()
+
+	if c.config.OnStateChange != nil {
+		c.config.OnStateChange(state, err)
+	}
+}
+
+// resume 请求服务端恢复断开前的会话
+func (c *Client) resume(session string) {
+	resp, err := c.sendRestore(ReqMessage{
+		Method: RESUME,
+		Path:   SessionPath,
+		Header: DefaultHeader.clone(),
+		Body:   N{"session": session},
+	})
+	if err != nil {
+		c.logger.Warn("resume session failed", "session", session, "error", err)
+		return
 	}
+
+	if token, ok := bodyString(resp.Body, "session"); ok {
+		c.mu.Lock()
+		c.session = token
+		c.mu.Unlock()
+	}
+	body, _ := resp.Body.(map[string]any)
+	resumed, _ := body["resumed"].(bool)
+	c.logger.Debug("session resumed", "resumed", resumed)
+}
+
+// sendRestore 发送重连后恢复会话和订阅的请求，超时时间为 RequestTimeout
+func (c *Client) sendRestore(data ReqMessage) (ResMessage, error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), restoreKey{}, true), c.config.RequestTimeout)
+	defer cancel()
+	return c.SendRequestContext(ctx, data)
+}
+
+// SessionID 返回服务端分配的会话令牌，服务端未启用会话时为空
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
 }
 
 // Req 创建请求消息
@@ -166,81 +515,251 @@ func (c *Client) Do(req *ReqMessage) (*ResMessage, error) {
 	return &resp, nil
 }
 
-// SendRequest 方法用于发送请求并等待响应
+// DoContext 发送请求并等待响应，ctx的截止时间即为该请求的截止时间
+func (c *Client) DoContext(ctx context.Context, req *ReqMessage) (*ResMessage, error) {
+	resp, err := c.SendRequestContext(ctx, *req)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SendRequest 方法用于发送请求并等待响应，超时时间为 RequestTimeout
 func (c *Client) SendRequest(data ReqMessage) (ResMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.RequestTimeout)
+	defer cancel()
+	return c.SendRequestContext(ctx, data)
+}
+
+// SendRequestContext 发送请求并等待响应，直到收到响应或ctx结束
+// 请求会依次经过 Use 注册的拦截器
+// 请求头中会注入traceparent，ctx中带有跨度或请求头中已有traceparent时延续该追踪
+// 启用 QueueSize 且连接断开时，请求会在队列中等待重连成功后发送
+func (c *Client) SendRequestContext(ctx context.Context, data ReqMessage) (ResMessage, error) {
+	// 创建客户端跨度并注入请求头，复制请求头避免修改调用方的map
+	parent := traceparentFromHeader(data.Header)
+	if s := SpanFromContext(ctx); s != nil {
+		parent = s.SpanContext()
+	}
+	span := startSpan(data.Method+" "+data.Path, SpanKindClient, parent, c.config.SpanExporter)
+	data.Header = data.Header.clone()
+	data.Header[TraceparentHeader] = span.SpanContext().Traceparent()
+	ctx = ContextWithSpan(ctx, span)
+
+	resp, err := c.intercept(ctx, &data)
+
+	span.SetAttribute("nexus.method", data.Method)
+	span.SetAttribute("nexus.path", data.Path)
+	if err != nil {
+		span.SetError(err)
+	} else {
+		span.SetAttribute("nexus.status", int(resp.Status))
+	}
+	span.Finish()
+	return resp, err
+}
+
+// intercept 依次经过拦截器后发送请求
+func (c *Client) intercept(ctx context.Context, data *ReqMessage) (ResMessage, error) {
 	c.mu.Lock()
-	if !c.connected {
-		c.mu.Unlock()
-		return ResMessage{}, errors.New("客户端未连接")
+	interceptors := c.interceptors
+	c.mu.Unlock()
+
+	invoker := c.invoke
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, req *ReqMessage) (ResMessage, error) {
+			return interceptor(ctx, req, next)
+		}
 	}
+	return invoker(ctx, data)
+}
+
+// invoke 发送请求并等待响应，是拦截器链的最后一环
+func (c *Client) invoke(ctx context.Context, req *ReqMessage) (ResMessage, error) {
+	data := *req
 
 	// 确保请求有ID
 	if data.ID == "" {
 		data.ID = GenerateUniqueString()
 	}
 
-	// 设置时间戳
-	data.Timestamp = time.Now()
-
 	// 创建响应通道
 	respChan := make(chan ResMessage, 1)
+
+	c.mu.Lock()
+	// 重连后恢复完成之前，普通请求排在队列中已有的请求之后
+	queued := c.restoring && c.queueEnabled() && ctx.Value(restoreKey{}) == nil
+	if !c.connected || queued {
+		if !c.queueEnabled() {
+			c.mu.Unlock()
+			return ResMessage{}, errors.New("客户端未连接")
+		}
+		if len(c.queue) >= c.config.QueueSize {
+			c.mu.Unlock()
+			return ResMessage{}, errors.New("请求队列已满")
+		}
+		// 连接断开期间放入队列，重连成功后发送
+		c.pending[data.ID] = respChan
+		c.queue = append(c.queue, data)
+		c.mu.Unlock()
+		return c.waitResponse(ctx, data.ID, respChan)
+	}
+
+	// 设置时间戳
+	data.Timestamp = time.Now()
 	c.pending[data.ID] = respChan
 
 	// 发送请求
-	err := c.conn.WriteMessage(websocket.TextMessage, data.Bytes())
+	err := c.conn.WriteFrame(data.Bytes())
+	requeued := false
+	if err != nil {
+		if c.queueEnabled() && len(c.queue) < c.config.QueueSize {
+			// 写入失败说明连接已断开，放入队列等待重连
+			c.queue = append(c.queue, data)
+			requeued = true
+		} else {
+			delete(c.pending, data.ID)
+		}
+	}
 	c.mu.Unlock()
 
 	if err != nil {
-		c.mu.Lock()
-		delete(c.pending, data.ID)
-		c.mu.Unlock()
-
 		// 触发重连
-		if c.config.AutoReconnect {
-			c.mu.Lock()
-			if c.connected {
-				c.connected = false
-				c.mu.Unlock()
-				c.reconnectChan <- struct{}{}
-			} else {
-				c.mu.Unlock()
-			}
+		c.triggerReconnect()
+		if !requeued {
+			return ResMessage{}, fmt.Errorf("发送请求失败: %w", err)
 		}
+	}
+
+	return c.waitResponse(ctx, data.ID, respChan)
+}
+
+// Invoker 发送请求并返回响应，拦截器通过调用它把请求交给链中的下一环
+type Invoker func(ctx context.Context, req *ReqMessage) (ResMessage, error)
+
+// Interceptor 包裹客户端发出的每个请求，可以修改请求、检查响应或多次调用next实现重试
+type Interceptor func(ctx context.Context, req *ReqMessage, next Invoker) (ResMessage, error)
+
+// Use 注册请求拦截器，先注册的拦截器在外层
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 复制后追加，避免正在执行的请求读到修改中的切片
+	c.interceptors = append(append([]Interceptor(nil), c.interceptors...), interceptors...)
+}
+
+// UsePush 注册推送消息的中间件，用法与服务端中间件相同
+// 中间件对每条推送消息执行一次，在分发给匹配的订阅之前执行，调用 Exit 可以丢弃消息
+// 中间件中的上下文不含路由参数，设置的键值会复制给每个订阅的处理函数
+func (c *Client) UsePush(middleware ...HandlerFunc) {
+	c.subscriptionsMu.Lock()
+	defer c.subscriptionsMu.Unlock()
+	c.pushHandlers = append(append(HandlerFuncList(nil), c.pushHandlers...), middleware...)
+}
 
-		return ResMessage{}, fmt.Errorf("发送请求失败: %w", err)
+// triggerReconnect 将连接标记为断开并通知重连协程
+func (c *Client) triggerReconnect() {
+	if !c.config.AutoReconnect {
+		return
+	}
+	c.mu.Lock()
+	if c.connected {
+		c.connected = false
+		c.mu.Unlock()
+		c.signalReconnect()
+	} else {
+		c.mu.Unlock()
 	}
+}
 
-	// 等待响应或超时
+// waitResponse 等待响应、ctx结束或客户端关闭
+func (c *Client) waitResponse(ctx context.Context, id string, respChan chan ResMessage) (ResMessage, error) {
 	select {
-	case resp := <-respChan:
+	case resp, ok := <-respChan:
+		if !ok {
+			return ResMessage{}, errors.New("客户端已关闭")
+		}
 		return resp, nil
-	case <-time.After(c.config.RequestTimeout):
+	case <-ctx.Done():
 		c.mu.Lock()
-		delete(c.pending, data.ID)
+		delete(c.pending, id)
+		c.removeQueued(id)
 		c.mu.Unlock()
-		return ResMessage{}, errors.New("请求超时")
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ResMessage{}, errors.New("请求超时")
+		}
+		return ResMessage{}, ctx.Err()
 	case <-c.closeChan:
 		return ResMessage{}, errors.New("客户端已关闭")
 	}
 }
 
+// queueEnabled 判断是否启用了断线请求队列
+func (c *Client) queueEnabled() bool {
+	return c.config.AutoReconnect && c.config.QueueSize > 0
+}
+
+// removeQueued 从队列中移除请求，调用方需持有 mu
+func (c *Client) removeQueued(id string) {
+	for i, req := range c.queue {
+		if req.ID == id {
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// flushQueue 在重连成功后按顺序发送队列中的请求，全部发送后结束恢复状态
+// 连接在此期间再次断开时返回false
+func (c *Client) flushQueue() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.queue) > 0 && c.connected {
+		req := c.queue[0]
+		req.Timestamp = time.Now()
+		if err := c.conn.WriteFrame(req.Bytes()); err != nil {
+			c.logger.Warn("failed to flush queued request", "request_id", req.ID, "error", err)
+			return false
+		}
+		c.queue = c.queue[1:]
+	}
+	if !c.connected {
+		return false
+	}
+	c.restoring = false
+	return true
+}
+
 // readMessages 方法在单独的 goroutine 中运行，持续读取服务器消息
 func (c *Client) readMessages() {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	var readErr error
 	defer func() {
+		// 连接已被替换时不影响新连接的状态
 		c.mu.Lock()
-		wasConnected := c.connected
-		c.connected = false
-		conn := c.conn
+		current := c.conn == conn
+		wasConnected := c.connected && current
+		if current {
+			c.connected = false
+		}
 		c.mu.Unlock()
 
 		// 关闭连接
-		if conn != nil {
-			conn.Close()
-		}
+		conn.Close()
 
-		// 触发重连
-		if wasConnected && c.config.AutoReconnect {
-			c.reconnectChan <- struct{}{}
+		if !wasConnected {
+			return
+		}
+		// 触发重连，未启用自动重连时等待手动 Reconnect
+		if c.config.AutoReconnect {
+			c.signalReconnect()
+		} else {
+			c.setState(StateFailed, readErr)
 		}
 	}()
 
@@ -251,100 +770,333 @@ func (c *Client) readMessages() {
 		default:
 			// 检查连接状态
 			c.mu.Lock()
-			if !c.connected {
+			if !c.connected || c.conn != conn {
 				c.mu.Unlock()
 				return
 			}
-			conn := c.conn
 			c.mu.Unlock()
 
 			// 读取消息
-			_, message, err := conn.ReadMessage()
+			message, err := conn.ReadFrame()
 			if err != nil {
-				if c.config.Debug {
-					log.Printf("[ERROR] Read error: %v", err)
+				// 客户端主动关闭或已切换到新连接导致的读取错误不记录
+				c.mu.Lock()
+				current := c.connected && c.conn == conn
+				c.mu.Unlock()
+				if current {
+					c.logger.Warn("read failed", "error", err)
 				}
+				readErr = err
 				return
 			}
 
+			// 收到任何消息都说明连接仍然存活
+			if c.config.HeartbeatTimeout > 0 {
+				conn.SetReadDeadline(time.Now().Add(c.config.HeartbeatTimeout))
+			}
+
 			// 解析响应
 			var resp ResMessage
 			if err = json.Unmarshal(message, &resp); err != nil {
-				if c.config.Debug {
-					log.Printf("[ERROR] Failed to parse response: %v", err)
-				}
+				c.logger.Warn("failed to parse response", "error", err)
 				continue
 			}
 
-			// 处理响应
-			c.mu.Lock()
-			if ch, ok := c.pending[resp.ID]; ok {
-				// 将响应发送到等待通道
-				select {
-				case ch <- resp:
-				default:
-					// 通道已满或已关闭，忽略响应
-				}
-				delete(c.pending, resp.ID)
-				c.mu.Unlock()
-			} else {
-				c.mu.Unlock()
+			c.dispatch(resp)
+		}
+	}
+}
 
-				// 处理订阅消息
-				c.handleSubscription(resp)
+// dispatch 分发一条服务端消息：系统消息、请求响应或订阅推送
+func (c *Client) dispatch(resp ResMessage) {
+	switch resp.Header["path"] {
+	case SessionPath:
+		// 记录服务端推送的会话令牌
+		if resp.ID == "" {
+			if token, ok := bodyString(resp.Body, "session"); ok {
+				c.mu.Lock()
+				c.session = token
+				c.mu.Unlock()
 			}
+			return
+		}
+	case ReliablePath:
+		// 可靠投递的推送消息，确认后按消息ID去重再分发原始消息
+		inner, ok := c.acceptReliable(resp)
+		if !ok {
+			return
 		}
+		resp = inner
 	}
+
+	// 处理响应
+	c.mu.Lock()
+	if ch, ok := c.pending[resp.ID]; ok {
+		// 将响应发送到等待通道
+		select {
+		case ch <- resp:
+		default:
+			// 通道已满或已关闭，忽略响应
+		}
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+	} else {
+		c.mu.Unlock()
+
+		// 处理订阅消息
+		c.handleSubscription(resp)
+	}
+}
+
+// acceptReliable 确认可靠投递的推送消息并解出原始消息，重复投递的消息返回false
+func (c *Client) acceptReliable(resp ResMessage) (ResMessage, bool) {
+	ack := ReqMessage{
+		ID:     GenerateUniqueString(),
+		Method: ACK,
+		Path:   ReliablePath,
+		Header: DefaultHeader.clone(),
+		Body:   N{"seq": resp.Header["seq"]},
+	}
+
+	c.mu.Lock()
+	if c.connected {
+		if err := c.conn.WriteFrame(ack.Bytes()); err != nil {
+			c.logger.Warn("failed to send ack", "seq", resp.Header["seq"], "error", err)
+		}
+	}
+	_, duplicate := c.delivered[resp.ID]
+	if !duplicate {
+		c.delivered[resp.ID] = struct{}{}
+		c.deliveredOrder = append(c.deliveredOrder, resp.ID)
+		if len(c.deliveredOrder) > dedupWindow {
+			delete(c.delivered, c.deliveredOrder[0])
+			c.deliveredOrder = c.deliveredOrder[1:]
+		}
+	}
+	c.mu.Unlock()
+
+	if duplicate {
+		return ResMessage{}, false
+	}
+
+	var inner ResMessage
+	data, err := json.Marshal(resp.Body)
+	if err == nil {
+		err = json.Unmarshal(data, &inner)
+	}
+	if err != nil {
+		c.logger.Warn("failed to parse pushed message", "error", err)
+		return ResMessage{}, false
+	}
+	return inner, true
+}
+
+// subscription 表示客户端的一个订阅
+type subscription struct {
+	handler HandlerFunc
+	// pattern 通配主题的匹配树，普通主题为nil，按字符串相等匹配
+	pattern *topicPattern
 }
 
-// Subscribe 订阅特定路径的消息
-func (c *Client) Subscribe(path string, handler HandlerFunc) {
+// match 判断推送消息的路径是否匹配订阅，并返回提取出的参数
+func (s *subscription) match(topic, path string) (Params, bool) {
+	if s.pattern == nil {
+		return nil, topic == path
+	}
+	return s.pattern.match(path)
+}
+
+// Subscribe 向服务端订阅主题，服务端允许后才会收到该主题的推送消息
+// 服务端没有注册该主题的 SUBSCRIBE 路由时只在本地登记处理函数，用于接收服务端直接推送到该路径的消息
+// 主题可以是 /rooms/:id/messages 或 /events/*rest 这样的模式，提取出的参数可通过 Context.Param 获取
+// 订阅会在重连成功后自动恢复
+func (c *Client) Subscribe(topic string, handler HandlerFunc) error {
+	sub := &subscription{handler: handler}
+	if isTopicPattern(topic) {
+		pattern, err := newTopicPattern(topic)
+		if err != nil {
+			return err
+		}
+		sub.pattern = pattern
+	}
+
+	// 先注册处理函数，避免错过订阅成功后立即到达的推送
 	c.subscriptionsMu.Lock()
-	defer c.subscriptionsMu.Unlock()
-	c.subscriptions[path] = handler
+	previous, existed := c.subscriptions[topic]
+	c.subscriptions[topic] = sub
+	c.subscriptionsMu.Unlock()
+
+	if err := c.subscribe(topic, false); err != nil {
+		c.subscriptionsMu.Lock()
+		if existed {
+			c.subscriptions[topic] = previous
+		} else {
+			delete(c.subscriptions, topic)
+		}
+		c.subscriptionsMu.Unlock()
+		return err
+	}
+	return nil
 }
 
-// Unsubscribe 取消订阅
-func (c *Client) Unsubscribe(path string) {
+// Unsubscribe 取消订阅主题，本地处理函数总是会被移除
+func (c *Client) Unsubscribe(topic string) error {
 	c.subscriptionsMu.Lock()
-	defer c.subscriptionsMu.Unlock()
-	delete(c.subscriptions, path)
+	delete(c.subscriptions, topic)
+	c.subscriptionsMu.Unlock()
+
+	resp, err := c.SendRequest(ReqMessage{Method: UNSUBSCRIBE, Path: topic, Header: DefaultHeader.clone()})
+	if err != nil {
+		return err
+	}
+	if resp.Status < 200 || resp.Status >= 300 {
+		return fmt.Errorf("取消订阅失败: %d", resp.Status)
+	}
+	return nil
 }
 
-// handleSubscription 处理订阅消息
+// subscribe 发送订阅请求并检查服务端的响应状态，restore 表示重连后恢复订阅
+func (c *Client) subscribe(topic string, restore bool) error {
+	req := ReqMessage{Method: SUBSCRIBE, Path: topic, Header: DefaultHeader.clone()}
+	var resp ResMessage
+	var err error
+	if restore {
+		resp, err = c.sendRestore(req)
+	} else {
+		resp, err = c.SendRequest(req)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Status == StatusNotFound {
+		// 服务端没有订阅路由，退化为本地订阅
+		c.logger.Debug("subscribe route not found, subscribing locally", "topic", topic)
+		return nil
+	}
+	if resp.Status < 200 || resp.Status >= 300 {
+		return &SubscribeError{Topic: topic, Status: resp.Status}
+	}
+	return nil
+}
+
+// SubscribeError 表示订阅被服务端拒绝
+type SubscribeError struct {
+	Topic  string
+	Status status
+}
+
+func (e *SubscribeError) Error() string {
+	return fmt.Sprintf("订阅 %s 被拒绝: %d", e.Topic, e.Status)
+}
+
+// resubscribe 在重连成功后重新订阅所有主题，失败通过 OnResubscribeError 报告
+// 被服务端拒绝的订阅会被移除，其他错误保留订阅等待下一次重连
+func (c *Client) resubscribe() {
+	c.subscriptionsMu.Lock()
+	topics := make([]string, 0, len(c.subscriptions))
+	for topic := range c.subscriptions {
+		topics = append(topics, topic)
+	}
+	c.subscriptionsMu.Unlock()
+
+	for _, topic := range topics {
+		err := c.subscribe(topic, true)
+		if err == nil {
+			continue
+		}
+		c.logger.Warn("resubscribe failed", "topic", topic, "error", err)
+		var se *SubscribeError
+		if errors.As(err, &se) {
+			c.subscriptionsMu.Lock()
+			delete(c.subscriptions, topic)
+			c.subscriptionsMu.Unlock()
+		}
+		if c.config.OnResubscribeError != nil {
+			c.config.OnResubscribeError(topic, err)
+		}
+	}
+}
+
+// handleSubscription 处理推送消息，先执行推送中间件，再分发给所有匹配消息路径的订阅
+// 中间件对每条推送只执行一次，没有匹配的订阅时也会执行
 func (c *Client) handleSubscription(resp ResMessage) {
+	path, ok := resp.Header["path"].(string)
+	if !ok {
+		return
+	}
+
 	c.subscriptionsMu.Lock()
-	defer c.subscriptionsMu.Unlock()
+	middleware := c.pushHandlers
+	c.subscriptionsMu.Unlock()
 
-	for path, handler := range c.subscriptions {
-		// TODO: 根据实际情况实现路径匹配逻辑
-		if resp.Header != nil && resp.Header["path"] == path {
-			// 创建上下文
-			ctx := &Context{
-				Response: &resp,
-				Header:   resp.Header,
-			}
+	msg := resp
+	ctx := NewContext(nil)
+	ctx.Request = &ReqMessage{
+		Path:   path,
+		Header: msg.Header,
+	}
+	ctx.Response = &msg
+	ctx.Header = msg.Header
+	ctx.handlers = append(append(HandlerFuncList(nil), middleware...), c.dispatchPush)
+
+	// 依次执行推送中间件，最后分发给订阅
+	go ctx.Next()
+}
+
+// dispatchPush 是推送中间件链的最后一环，把消息交给所有匹配路径的订阅
+// 每个订阅使用独立的上下文，路由参数放在请求中，消息头和键值为中间件处理后的副本
+func (c *Client) dispatchPush(ctx *Context) {
+	path := ctx.Request.Path
 
-			// 调用处理函数
-			go handler(ctx)
-			break
+	type matched struct {
+		handler HandlerFunc
+		params  Params
+	}
+	var subs []matched
+	c.subscriptionsMu.Lock()
+	for topic, sub := range c.subscriptions {
+		if params, ok := sub.match(topic, path); ok {
+			subs = append(subs, matched{handler: sub.handler, params: params})
 		}
 	}
+	c.subscriptionsMu.Unlock()
+
+	for _, sub := range subs {
+		msg := *ctx.Response
+		msg.Header = ctx.Response.Header.clone()
+		sc := NewContext(nil)
+		sc.Request = &ReqMessage{
+			Path:   path,
+			Params: sub.params,
+			Header: msg.Header,
+		}
+		sc.Response = &msg
+		sc.Header = msg.Header
+		for k, v := range ctx.Keys {
+			sc.Keys[k] = v
+		}
+		sc.handlers = HandlerFuncList{sub.handler}
+		go sc.Next()
+	}
 }
 
 // Close 方法用于关闭与 Nexus 服务的连接
 func (c *Client) Close() error {
 	c.mu.Lock()
-	if !c.connected {
+	select {
+	case <-c.closeChan:
 		c.mu.Unlock()
 		return nil
+	default:
 	}
+	wasConnected := c.connected
 	c.connected = false
 	conn := c.conn
+	// 关闭所有通道，重连中的客户端也会停止重连
+	close(c.closeChan)
 	c.mu.Unlock()
 
-	// 关闭所有通道
-	close(c.closeChan)
+	c.setState(StateClosed, nil)
 
 	// 关闭所有挂起的请求
 	c.mu.Lock()
@@ -355,8 +1107,8 @@ func (c *Client) Close() error {
 	c.mu.Unlock()
 
 	// 关闭WebSocket连接
-	if conn != nil {
-		return conn.Close()
+	if conn != nil && wasConnected {
+		return conn.CloseWithReason(CloseNormalClosure, "")
 	}
 
 	return nil
@@ -0,0 +1,424 @@
+package Nexus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordTransport 记录客户端写出的请求，其余行为与 PipeTransport 相同
+type recordTransport struct {
+	*PipeTransport
+	mu       sync.Mutex
+	requests []ReqMessage
+}
+
+// recordConn 在写出帧时记录请求
+type recordConn struct {
+	FrameConn
+	t *recordTransport
+}
+
+func (t *recordTransport) Dial(rawURL string, header http.Header) (FrameConn, error) {
+	fc, err := t.PipeTransport.Dial(rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	return &recordConn{FrameConn: fc, t: t}, nil
+}
+
+func (c *recordConn) WriteFrame(data []byte) error {
+	var req ReqMessage
+	if json.Unmarshal(data, &req) == nil && req.Method != ACK {
+		c.t.mu.Lock()
+		c.t.requests = append(c.t.requests, req)
+		c.t.mu.Unlock()
+	}
+	return c.FrameConn.WriteFrame(data)
+}
+
+// reset 清空已记录的请求
+func (t *recordTransport) reset() {
+	t.mu.Lock()
+	t.requests = nil
+	t.mu.Unlock()
+}
+
+// paths 返回已记录请求的方法和路径
+func (t *recordTransport) paths() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	paths := make([]string, len(t.requests))
+	for i, req := range t.requests {
+		paths[i] = req.Method + " " + req.Path
+	}
+	return paths
+}
+
+// newTestClient 创建通过内存管道连接到Engine的客户端
+func newTestClient(t *testing.T, e *Engine, configure func(*ClientConfig)) (*Client, *recordTransport) {
+	t.Helper()
+	transport := &recordTransport{PipeTransport: NewPipeTransport(e)}
+	cfg := DefaultClientConfig()
+	cfg.Transport = transport
+	cfg.HeartbeatInterval = 0
+	cfg.ReconnectJitter = 0
+	if configure != nil {
+		configure(&cfg)
+	}
+	client, err := NewClientWithConfig("ws", "pipe", "/", cfg)
+	if err != nil {
+		t.Fatalf("NewClientWithConfig: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, transport
+}
+
+// dropServerSide 从服务端断开客户端的连接
+func dropServerSide(t *testing.T, e *Engine) {
+	t.Helper()
+	for _, conn := range e.Connections() {
+		conn.Close(CloseGoingAway, "drop")
+	}
+}
+
+func TestClientQueueFlushedBeforeNewRequests(t *testing.T) {
+	e := New()
+	var subscribes int
+	var subscribesMu sync.Mutex
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	e.SUBSCRIBE("/topic", func(c *Context) {
+		subscribesMu.Lock()
+		subscribes++
+		n := subscribes
+		subscribesMu.Unlock()
+		// 重连后的恢复订阅阻塞，直到测试放行
+		if n == 2 {
+			close(entered)
+			<-release
+		}
+	})
+	e.GET("/a", func(c *Context) {})
+	e.GET("/b", func(c *Context) {})
+
+	client, transport := newTestClient(t, e, func(cfg *ClientConfig) {
+		cfg.QueueSize = 10
+		cfg.ReconnectInterval = 100 * time.Millisecond
+	})
+	if err := client.Subscribe("/topic", func(c *Context) {}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	dropServerSide(t, e)
+	waitFor(t, func() bool { return client.State() == StateReconnecting })
+	transport.reset()
+
+	results := make(chan error, 2)
+	send := func(path string) {
+		_, err := client.SendRequest(ReqMessage{Method: GET, Path: path})
+		results <- err
+	}
+	go send("/a")
+	waitFor(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.queue) == 1
+	})
+
+	// 恢复订阅期间发出的请求应排在队列中的请求之后
+	<-entered
+	if state := client.State(); state == StateConnected {
+		t.Fatal("state is connected before the queue was flushed")
+	}
+	go send("/b")
+	waitFor(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.queue) == 2
+	})
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+	want := []string{"SUBSCRIBE /topic", "GET /a", "GET /b"}
+	got := transport.paths()
+	if len(got) != len(want) {
+		t.Fatalf("written = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("written = %v, want %v", got, want)
+		}
+	}
+	waitFor(t, func() bool { return client.State() == StateConnected })
+}
+
+func TestClientReconnectFirstAttemptImmediate(t *testing.T) {
+	e := New()
+	client, _ := newTestClient(t, e, func(cfg *ClientConfig) {
+		cfg.AutoReconnect = false
+		cfg.ReconnectInterval = time.Hour
+	})
+
+	waitFor(t, func() bool { return len(e.Connections()) == 1 })
+	old := e.Connections()[0]
+
+	start := time.Now()
+	if err := client.Reconnect(); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	waitFor(t, func() bool {
+		conns := e.Connections()
+		return len(conns) == 1 && conns[0] != old && client.State() == StateConnected
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("reconnected after %v, want immediately", elapsed)
+	}
+}
+
+// mutePongTransport 可以丢弃心跳响应，模拟服务端不再响应心跳，新建立的连接总是正常响应
+type mutePongTransport struct {
+	*PipeTransport
+	muted atomic.Bool
+	dials atomic.Int32
+}
+
+// mutePongConn 在静默时不把心跳响应交给客户端
+type mutePongConn struct {
+	FrameConn
+	t *mutePongTransport
+}
+
+func (t *mutePongTransport) Dial(rawURL string, header http.Header) (FrameConn, error) {
+	fc, err := t.PipeTransport.Dial(rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	t.muted.Store(false)
+	t.dials.Add(1)
+	return &mutePongConn{FrameConn: fc, t: t}, nil
+}
+
+func (c *mutePongConn) SetPongHandler(h func()) {
+	c.FrameConn.SetPongHandler(func() {
+		if !c.t.muted.Load() {
+			h()
+		}
+	})
+}
+
+func TestClientHeartbeatTimeoutReconnects(t *testing.T) {
+	const (
+		interval = 20 * time.Millisecond
+		timeout  = 200 * time.Millisecond
+	)
+	e := New()
+	transport := &mutePongTransport{PipeTransport: NewPipeTransport(e)}
+	cfg := DefaultClientConfig()
+	cfg.Transport = transport
+	cfg.HeartbeatInterval = interval
+	cfg.HeartbeatTimeout = timeout
+	cfg.ReconnectInterval = interval
+	cfg.ReconnectJitter = 0
+	client, err := NewClientWithConfig("ws", "pipe", "/", cfg)
+	if err != nil {
+		t.Fatalf("NewClientWithConfig: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	// 心跳有响应时连接在超过读取超时后仍然保持
+	time.Sleep(2 * timeout)
+	if dials := transport.dials.Load(); dials != 1 || client.State() != StateConnected {
+		t.Fatalf("dials = %d, state = %v, want the first connection kept alive by heartbeats", dials, client.State())
+	}
+
+	transport.muted.Store(true)
+	start := time.Now()
+	waitFor(t, func() bool { return transport.dials.Load() == 2 && client.State() == StateConnected })
+	elapsed := time.Since(start)
+	if elapsed < timeout-interval || elapsed > timeout+timeout/2 {
+		t.Fatalf("reconnected %v after heartbeats stopped, want within HeartbeatTimeout %v", elapsed, timeout)
+	}
+	waitFor(t, func() bool { return len(e.Connections()) == 1 })
+}
+
+func TestClientSubscribe(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   HandlerFunc
+		wantErr bool
+		wantMsg bool
+	}{
+		{"allowed by route", func(c *Context) {}, false, true},
+		{"rejected by route", func(c *Context) {
+			c.JSON(StatusForbidden, N{})
+			c.Exit()
+		}, true, false},
+		{"no route subscribes locally", nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New()
+			if tt.route != nil {
+				e.SUBSCRIBE("/news", tt.route)
+			}
+			client, _ := newTestClient(t, e, nil)
+
+			received := make(chan string, 1)
+			err := client.Subscribe("/news", func(c *Context) {
+				body, _ := c.Response.Body.(map[string]any)
+				received <- body["text"].(string)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Subscribe error = %v, want error: %v", err, tt.wantErr)
+			}
+
+			// 服务端直接推送到该路径
+			push := &ResMessage{Status: StatusOK, Header: header{"path": "/news"}, Body: N{"text": "hi"}}
+			for _, conn := range e.Connections() {
+				conn.Send(push.Bytes())
+			}
+			select {
+			case got := <-received:
+				if !tt.wantMsg || got != "hi" {
+					t.Fatalf("received %q, want message: %v", got, tt.wantMsg)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantMsg {
+					t.Fatal("push not delivered to subscription")
+				}
+			}
+		})
+	}
+}
+
+func TestClientUnsubscribeRoute(t *testing.T) {
+	e := New()
+	unsubscribed := make(chan string, 1)
+	e.SUBSCRIBE("/news", func(c *Context) {})
+	e.UNSUBSCRIBE("/news", func(c *Context) { unsubscribed <- c.Request.Path })
+	client, _ := newTestClient(t, e, nil)
+
+	if err := client.Subscribe("/news", func(c *Context) {}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	waitFor(t, func() bool { return len(e.Subscribers("/news")) == 1 })
+	if err := client.Unsubscribe("/news"); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	select {
+	case path := <-unsubscribed:
+		if path != "/news" {
+			t.Fatalf("UNSUBSCRIBE route got %q", path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("UNSUBSCRIBE route not called")
+	}
+	waitFor(t, func() bool { return len(e.Subscribers("/news")) == 0 })
+}
+
+func TestClientInternalRequestsHaveHeader(t *testing.T) {
+	e := New()
+	e.SUBSCRIBE("/news", func(c *Context) {})
+	client, _ := newTestClient(t, e, nil)
+
+	var mu sync.Mutex
+	seen := make(map[string]header)
+	client.Use(func(ctx context.Context, req *ReqMessage, next Invoker) (ResMessage, error) {
+		mu.Lock()
+		seen[req.Method] = req.Header
+		mu.Unlock()
+		return next(ctx, req)
+	})
+	if err := client.Subscribe("/news", func(c *Context) {}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := client.Unsubscribe("/news"); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, method := range []string{SUBSCRIBE, UNSUBSCRIBE} {
+		if h := seen[method]; h["Content-Type"] != "application/json" {
+			t.Errorf("%s header = %v, want default header", method, h)
+		}
+	}
+}
+
+func TestClientPushMiddlewareOncePerPush(t *testing.T) {
+	tests := []struct {
+		name         string
+		topics       []string
+		exit         bool
+		wantHandlers int32
+	}{
+		{"no matching subscription", []string{"/other"}, false, 0},
+		{"one subscription", []string{"/rooms/7"}, false, 1},
+		{"two matching subscriptions", []string{"/rooms/7", "/rooms/:id"}, false, 2},
+		{"middleware drops push", []string{"/rooms/7", "/rooms/:id"}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New()
+			client, _ := newTestClient(t, e, nil)
+
+			var middleware, handlers atomic.Int32
+			client.UsePush(func(c *Context) {
+				middleware.Add(1)
+				c.Set("seen", true)
+				if tt.exit {
+					c.Exit()
+				}
+			})
+			for _, topic := range tt.topics {
+				if err := client.Subscribe(topic, func(c *Context) {
+					if c.Get("seen") == true {
+						handlers.Add(1)
+					}
+				}); err != nil {
+					t.Fatalf("Subscribe: %v", err)
+				}
+			}
+
+			push := &ResMessage{Status: StatusOK, Header: header{"path": "/rooms/7"}, Body: N{}}
+			for _, conn := range e.Connections() {
+				conn.Send(push.Bytes())
+			}
+			waitFor(t, func() bool { return middleware.Load() == 1 })
+			time.Sleep(50 * time.Millisecond)
+			if middleware.Load() != 1 {
+				t.Fatalf("middleware ran %d times, want once", middleware.Load())
+			}
+			if handlers.Load() != tt.wantHandlers {
+				t.Fatalf("handlers ran %d times, want %d", handlers.Load(), tt.wantHandlers)
+			}
+		})
+	}
+}
+
+func TestClientConfigDialerHandshakeTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"unset", 0, 45 * time.Second},
+		{"negative", -time.Second, 45 * time.Second},
+		{"configured", 5 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		cfg := ClientConfig{HandshakeTimeout: tt.timeout}
+		if got := cfg.dialer().HandshakeTimeout; got != tt.want {
+			t.Errorf("%s: HandshakeTimeout = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
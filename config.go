@@ -1,6 +1,11 @@
 package Nexus
 
-import "time"
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
 
 // Config 表示Nexus引擎的配置选项
 type Config struct {
@@ -10,6 +15,26 @@ type Config struct {
 	ConnectionConfig ConnectionConfig
 	// 日志配置
 	LogConfig LogConfig
+	// 访问日志配置
+	AccessLogConfig AccessLogConfig
+	// 在线状态配置
+	PresenceConfig PresenceConfig
+	// 集群配置
+	ClusterConfig ClusterConfig
+	// 会话配置
+	SessionConfig SessionConfig
+	// 可靠投递配置
+	ReliableConfig ReliableConfig
+	// 离线消息配置
+	OfflineConfig OfflineConfig
+	// 指标配置
+	MetricsConfig MetricsConfig
+	// 追踪配置
+	TracingConfig TracingConfig
+	// 管理接口配置
+	AdminConfig AdminConfig
+	// API文档配置
+	SchemaConfig SchemaConfig
 }
 
 // WebSocketConfig WebSocket相关配置
@@ -40,14 +65,127 @@ type ConnectionConfig struct {
 
 // LogConfig 日志相关配置
 type LogConfig struct {
-	// 是否启用调试日志
+	// 是否启用调试日志，未设置子系统级别时调试模式输出所有日志，否则输出警告和错误
 	Debug bool
-	// 是否记录访问日志
+	// 是否记录访问日志，输出方式由 AccessLogConfig 配置
 	AccessLog bool
-	// 日志格式
+	// 日志格式，text 或 json，配置了 Logger 时不生效
 	Format string
+	// 日志输出，为nil时输出到标准错误，配置了 Logger 时不生效
+	Output io.Writer
+	// 自定义日志记录器，为nil时按 Format 和 Output 创建
+	Logger *slog.Logger
+	// 各子系统的日志级别，键为 LogEngine、LogConnection 等子系统名称
+	Levels map[string]slog.Level
 }
 
+// AccessLogConfig 访问日志相关配置，LogConfig.AccessLog 开启时生效
+type AccessLogConfig struct {
+	// 日志模板，可以是 AccessLogCombined、AccessLogJSON 或引用 AccessLogEntry 字段的 text/template 模板
+	// 为空时通过access子系统的slog日志记录器输出
+	Format string
+	// 日志输出，为nil时输出到标准输出，可以使用 NewRotatingFile 写入按大小轮转的文件
+	Output io.Writer
+}
+
+// PresenceConfig 在线状态相关配置
+type PresenceConfig struct {
+	// 是否向房间成员推送上下线变化
+	Enabled bool
+	// 推送消息头中的路径，客户端可据此订阅
+	Path string
+}
+
+// ClusterConfig 多节点集群相关配置
+type ClusterConfig struct {
+	// 消息代理，为nil时广播和定向消息只在本节点内投递
+	Broker Broker
+	// 频道名称前缀，用于多个集群共享同一个代理
+	ChannelPrefix string
+	// 发给身份的消息在本节点无人接收时等待其他节点确认投递的时间
+	// 超时仍无节点确认且配置了离线消息存储时保存为离线消息
+	DeliveryTimeout time.Duration
+}
+
+// SessionConfig 会话恢复相关配置
+type SessionConfig struct {
+	// 是否启用会话恢复
+	Enabled bool
+	// 连接断开后会话的保留时间
+	Retention time.Duration
+	// 断开期间每个会话最多缓存的消息数
+	BufferSize int
+}
+
+// ReliableConfig 推送消息可靠投递相关配置
+type ReliableConfig struct {
+	// 是否启用至少一次投递
+	Enabled bool
+	// 首次等待确认的时间，之后每次重试翻倍
+	AckTimeout time.Duration
+	// 重试等待时间上限
+	MaxBackoff time.Duration
+	// 最大重试次数
+	MaxRetries int
+}
+
+// OfflineConfig 离线消息相关配置
+type OfflineConfig struct {
+	// 离线消息存储，为nil时发给离线用户的消息直接丢弃
+	// 存储实现 OfflineRoomStore 时，离线身份所在的房间也会持久化
+	Store MessageStore
+	// 离线身份所在房间的保留时间，超过后不再为其保存房间消息，为0时不过期
+	RoomRetention time.Duration
+}
+
+// MetricsConfig 运行指标相关配置
+type MetricsConfig struct {
+	// 是否收集指标
+	Enabled bool
+	// 以Prometheus文本格式输出指标的HTTP路径，由 Run 启动的服务器提供
+	Path string
+	// 请求耗时直方图的分桶，单位为秒，为空时使用 DefaultMetricsBuckets
+	Buckets []float64
+}
+
+// TracingConfig 分布式追踪相关配置
+type TracingConfig struct {
+	// 跨度导出器，为nil时不创建跨度
+	Exporter SpanExporter
+}
+
+// AdminConfig 管理接口相关配置
+// 管理接口可以查看路由、连接、房间和运行时信息，并可以断开连接，生产环境中应配置鉴权
+type AdminConfig struct {
+	// 是否在 Run 启动的服务器上提供HTTP管理接口
+	Enabled bool
+	// 管理接口的路径前缀，同时用于HTTP接口和Nexus路由
+	Path string
+	// HTTP管理接口的鉴权函数，返回false时拒绝请求，为nil时拒绝所有请求
+	Authorize func(r *http.Request) bool
+	// 是否同时注册为Nexus路由，供WebSocket客户端调用，启用时必须配置 Handlers
+	Routes bool
+	// 管理路由的中间件，在管理路由之前执行，负责鉴权
+	Handlers HandlerFuncList
+}
+
+// SchemaConfig API文档相关配置
+type SchemaConfig struct {
+	// 是否注册输出API文档的保留路由，同时在 Run 启动的服务器上提供HTTP接口
+	Enabled bool
+	// 文档路由的路径，为空时使用 /_schema
+	Path string
+	// 文档标题
+	Title string
+	// API版本
+	Version string
+	// 文档说明
+	Description string
+}
+
+// defaultSchemaPath 未配置 SchemaConfig.Path 时文档路由的路径
+const defaultSchemaPath = "/_schema"
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() Config {
 	return Config{
@@ -69,5 +207,44 @@ func DefaultConfig() Config {
 			AccessLog: false,
 			Format:    "text",
 		},
+		PresenceConfig: PresenceConfig{
+			Enabled: false,
+			Path:    "/presence",
+		},
+		ClusterConfig: ClusterConfig{
+			Broker:          nil,
+			ChannelPrefix:   "nexus.",
+			DeliveryTimeout: 2 * time.Second,
+		},
+		SessionConfig: SessionConfig{
+			Enabled:    false,
+			Retention:  2 * time.Minute,
+			BufferSize: 256,
+		},
+		ReliableConfig: ReliableConfig{
+			Enabled:    false,
+			AckTimeout: 2 * time.Second,
+			MaxBackoff: 30 * time.Second,
+			MaxRetries: 5,
+		},
+		OfflineConfig: OfflineConfig{
+			Store:         nil,
+			RoomRetention: 24 * time.Hour,
+		},
+		MetricsConfig: MetricsConfig{
+			Enabled: false,
+			Path:    "/metrics",
+		},
+		AdminConfig: AdminConfig{
+			Enabled: false,
+			Path:    "/debug/nexus",
+			Routes:  false,
+		},
+		SchemaConfig: SchemaConfig{
+			Enabled: false,
+			Path:    defaultSchemaPath,
+			Title:   "Nexus API",
+			Version: "1.0.0",
+		},
 	}
 }
@@ -1,25 +1,51 @@
 package Nexus
 
 import (
-	"log"
+	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// Connection 表示一个WebSocket连接
+// Connection 表示一个客户端连接
 type Connection struct {
-	ws         *websocket.Conn
-	send       chan []byte
-	engine     *Engine
-	closed     bool
-	closeMu    sync.Mutex
-	closeChan  chan struct{}
-	lastActive time.Time
+	id          string
+	remoteAddr  string
+	connectedAt time.Time
+	conn        FrameConn
+	send        chan []byte
+	engine      *Engine
+	closed      bool
+	closeCode   int
+	closeReason string
+	closeMu     sync.Mutex
+	closeChan   chan struct{}
+	lastActive  time.Time
+	identity    string
+	keys        map[string]any
+	keysMu      sync.RWMutex
+	session     *session
+	reliable    *reliableState
+	registered  bool
+	// virtual 由 ServeVirtualConn 创建，不注册到Engine
+	virtual bool
+	// queued、written 分别统计放入发送通道和已写出的帧数，供 Flush 使用
+	queued  atomic.Uint64
+	written atomic.Uint64
 }
 
+var (
+	// ErrConnectionNotFound 指定的连接不存在
+	ErrConnectionNotFound = errors.New("connection not found")
+	// ErrConnectionClosed 连接已关闭
+	ErrConnectionClosed = errors.New("connection closed")
+	// ErrSendQueueFull 连接的发送通道已满
+	ErrSendQueueFull = errors.New("send channel full")
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -43,36 +69,92 @@ func serveWs(e *Engine, w http.ResponseWriter, r *http.Request) {
 	// 升级HTTP连接到WebSocket
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		if e.config.LogConfig.Debug {
-			log.Printf("[ERROR] Upgrade error: %v", err)
-		}
+		e.log(LogConnection).Warn("upgrade failed", "remote", r.RemoteAddr, "error", err)
+		e.fireError(nil, err)
 		return
 	}
 
-	// 创建新的连接
-	conn := &Connection{
-		ws:         ws,
-		send:       make(chan []byte, e.config.ConnectionConfig.SendChannelSize),
-		engine:     e,
-		closed:     false,
-		closeChan:  make(chan struct{}),
-		lastActive: time.Now(),
+	e.serveConn(NewWebSocketConn(ws), r)
+}
+
+// ServeConn 使用任意帧连接创建并注册一个新连接，启动其读写协程
+// 连接钩子收到的升级请求为nil，钩子拒绝连接时返回其错误
+func (e *Engine) ServeConn(fc FrameConn) (*Connection, error) {
+	return e.serveConn(fc, nil)
+}
+
+// serveConn 创建连接，执行连接钩子后注册并启动读写协程
+func (e *Engine) serveConn(fc FrameConn, r *http.Request) (*Connection, error) {
+	conn := e.newConnection(fc)
+	if e.config.ReliableConfig.Enabled {
+		conn.reliable = newReliableState()
 	}
 
-	// 设置连接超时
-	ws.SetReadDeadline(time.Now().Add(e.config.ConnectionConfig.ConnectionTimeout))
-	ws.SetPongHandler(func(string) error {
-		conn.lastActive = time.Now()
-		ws.SetReadDeadline(time.Now().Add(e.config.ConnectionConfig.ConnectionTimeout))
-		return nil
-	})
+	// 执行连接钩子，任一钩子返回错误则拒绝连接
+	if err := e.fireConnect(conn, r); err != nil {
+		e.log(LogConnection).Info("connection rejected", "conn", conn.id, "remote", conn.remoteAddr, "error", err)
+		fc.CloseWithReason(ClosePolicyViolation, err.Error())
+		return nil, err
+	}
 
 	// 注册连接
 	e.register <- conn
+	e.metrics.connected()
+	conn.keysMu.Lock()
+	conn.registered = true
+	conn.keysMu.Unlock()
+
+	// 创建会话并推送会话令牌
+	if e.config.SessionConfig.Enabled {
+		e.startSession(conn)
+	}
+
+	// 连接钩子中已绑定身份时补发离线消息
+	e.replayOffline(conn)
 
 	// 启动读写协程
 	go conn.writePump()
 	go conn.readPump()
+	if conn.reliable != nil {
+		go conn.redeliverPump()
+	}
+
+	return conn, nil
+}
+
+// ServeVirtualConn 创建一个不注册到Engine的连接并启动写协程，供测试和进程内调用使用
+// 该连接不触发连接和断开钩子，不计入连接指标，不创建会话、不补发离线消息、不启用可靠投递，也不会收到广播
+// 连接不从fc读取请求，请求通过 Engine.ServeContext 交给Engine处理，响应和推送写入fc
+func (e *Engine) ServeVirtualConn(fc FrameConn) *Connection {
+	conn := e.newConnection(fc)
+	conn.virtual = true
+	go conn.writePump()
+	return conn
+}
+
+// newConnection 创建连接并设置连接超时和心跳响应回调
+func (e *Engine) newConnection(fc FrameConn) *Connection {
+	now := time.Now()
+	conn := &Connection{
+		id:          GenerateUniqueString(),
+		remoteAddr:  fc.RemoteAddr(),
+		connectedAt: now,
+		conn:        fc,
+		send:        make(chan []byte, e.config.ConnectionConfig.SendChannelSize),
+		engine:      e,
+		closed:      false,
+		closeChan:   make(chan struct{}),
+		lastActive:  now,
+		keys:        make(map[string]any),
+	}
+
+	// 设置连接超时
+	fc.SetReadDeadline(time.Now().Add(e.config.ConnectionConfig.ConnectionTimeout))
+	fc.SetPongHandler(func() {
+		conn.lastActive = time.Now()
+		fc.SetReadDeadline(time.Now().Add(e.config.ConnectionConfig.ConnectionTimeout))
+	})
+	return conn
 }
 
 // readPump 处理从WebSocket读取的消息
@@ -87,18 +169,28 @@ func (c *Connection) readPump() {
 			return
 		default:
 			// 读取消息
-			_, message, err := c.ws.ReadMessage()
+			message, err := c.conn.ReadFrame()
 			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					if c.engine.config.LogConfig.Debug {
-						log.Printf("[ERROR] Read error: %v", err)
+				var ce *CloseError
+				switch {
+				case errors.As(err, &ce):
+					if isUnexpectedCloseError(err) {
+						c.engine.log(LogConnection).Warn("read failed", "conn", c.id, "error", err)
+						c.engine.fireError(c, err)
 					}
+					c.closeWithReason(ce.Code, ce.Reason)
+				case c.isClosed():
+					// 本端主动关闭导致的读取错误
+				default:
+					c.engine.fireError(c, err)
+					c.closeWithReason(CloseAbnormalClosure, err.Error())
 				}
 				return
 			}
 
 			// 更新最后活动时间
 			c.lastActive = time.Now()
+			c.engine.metrics.received(len(message))
 
 			// 处理消息
 			go handleMessage(message, c, c.engine)
@@ -116,34 +208,32 @@ func (c *Connection) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			// 设置写入超时
-			c.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				// 通道已关闭
-				c.ws.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
+		case message := <-c.send:
 			// 发送消息
-			if err := c.ws.WriteMessage(websocket.TextMessage, message); err != nil {
-				if c.engine.config.LogConfig.Debug {
-					log.Printf("[ERROR] Write error: %v", err)
+			if err := c.conn.WriteFrame(message); err != nil {
+				c.engine.log(LogConnection).Warn("write failed", "conn", c.id, "error", err)
+				if !c.isClosed() {
+					c.engine.fireError(c, err)
+					c.closeWithReason(CloseAbnormalClosure, err.Error())
 				}
 				return
 			}
+			c.written.Add(1)
+			c.engine.metrics.sent(len(message))
 		case <-ticker.C:
 			// 发送心跳
-			c.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.conn.Ping(); err != nil {
+				if !c.isClosed() {
+					c.engine.fireError(c, err)
+					c.closeWithReason(CloseAbnormalClosure, err.Error())
+				}
 				return
 			}
 
 			// 检查连接是否超时
 			if time.Since(c.lastActive) > c.engine.config.ConnectionConfig.HeartbeatTimeout {
-				if c.engine.config.LogConfig.Debug {
-					log.Println("[INFO] Connection timeout")
-				}
+				c.engine.log(LogConnection).Info("heartbeat timeout", "conn", c.id)
+				c.closeWithReason(CloseAbnormalClosure, "heartbeat timeout")
 				return
 			}
 		case <-c.closeChan:
@@ -154,13 +244,208 @@ func (c *Connection) writePump() {
 
 // close 关闭连接
 func (c *Connection) close() {
+	c.closeWithReason(CloseAbnormalClosure, "")
+}
+
+// closeWithReason 关闭连接并记录关闭码和原因，随后执行断开钩子
+// CloseAbnormalClosure 不能出现在关闭帧中，此时只发送空的关闭帧
+func (c *Connection) closeWithReason(code int, reason string) {
+	c.closeMu.Lock()
+	if c.closed {
+		c.closeMu.Unlock()
+		return
+	}
+	c.closed = true
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.closeChan)
+	c.closeMu.Unlock()
+
+	// 虚拟连接未注册，只需退出通过订阅加入的房间
+	if c.virtual {
+		c.conn.Close()
+		c.engine.leaveAll(c)
+		return
+	}
+
+	c.engine.unregister <- c
+	c.engine.metrics.disconnected()
+	if code == CloseAbnormalClosure {
+		c.conn.Close()
+	} else {
+		c.conn.CloseWithReason(code, reason)
+	}
+
+	// 启用会话时保留房间成员身份直到会话过期，否则立即退出所有房间
+	if c.getSession() != nil {
+		c.engine.detachSession(c)
+	} else {
+		c.engine.leaveAll(c)
+	}
+
+	c.engine.fireDisconnect(c, code, reason)
+}
+
+// isClosed 判断连接是否已关闭
+func (c *Connection) isClosed() bool {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	return c.closed
+}
+
+// CloseStatus 返回连接关闭时的关闭码和原因，连接未关闭时返回0
+func (c *Connection) CloseStatus() (int, string) {
 	c.closeMu.Lock()
 	defer c.closeMu.Unlock()
+	return c.closeCode, c.closeReason
+}
+
+// ID 返回连接的唯一标识
+func (c *Connection) ID() string {
+	return c.id
+}
+
+// RemoteAddr 返回对端地址
+func (c *Connection) RemoteAddr() string {
+	return c.remoteAddr
+}
+
+// ConnectedAt 返回连接建立的时间
+func (c *Connection) ConnectedAt() time.Time {
+	return c.connectedAt
+}
+
+// Identity 返回连接绑定的用户身份
+func (c *Connection) Identity() string {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	return c.identity
+}
+
+// SetIdentity 绑定用户身份，同一身份可以对应多个连接
+// 连接已注册时绑定新身份会补发该身份的离线消息
+func (c *Connection) SetIdentity(identity string) {
+	c.keysMu.Lock()
+	changed := c.identity != identity
+	c.identity = identity
+	registered := c.registered
+	c.keysMu.Unlock()
+
+	if changed && registered {
+		c.engine.replayOffline(c)
+	}
+}
+
+// Get 获取连接上的元数据
+func (c *Connection) Get(key string) (any, bool) {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	value, ok := c.keys[key]
+	return value, ok
+}
+
+// Set 设置连接上的元数据
+func (c *Connection) Set(key string, value any) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+	c.keys[key] = value
+}
+
+// Metadata 返回连接元数据的副本
+func (c *Connection) Metadata() map[string]any {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	keys := make(map[string]any, len(c.keys))
+	for k, v := range c.keys {
+		keys[k] = v
+	}
+	return keys
+}
+
+// Send 将消息放入发送通道，通道已满时直接返回错误而不阻塞
+// 连接已断开但会话仍在保留期内时，消息会缓存到会话中等待恢复后补发
+// 启用可靠投递时，消息会带上序号，未被确认的消息会按退避策略重发
+func (c *Connection) Send(data []byte) error {
+	select {
+	case <-c.closeChan:
+		if s := c.getSession(); s != nil && s.push(c, data, c.engine.config.SessionConfig.BufferSize) {
+			return nil
+		}
+		return ErrConnectionClosed
+	default:
+	}
 
-	if !c.closed {
-		c.closed = true
-		close(c.closeChan)
-		c.engine.unregister <- c
-		c.ws.Close()
+	if c.reliable != nil {
+		return c.sendReliable(data)
+	}
+	err := c.enqueue(data)
+	if err != nil {
+		c.engine.metrics.drop()
+	}
+	return err
+}
+
+// enqueue 将帧放入发送通道，通道已满时返回错误
+func (c *Connection) enqueue(data []byte) error {
+	select {
+	case c.send <- data:
+		c.queued.Add(1)
+		return nil
+	default:
+		return ErrSendQueueFull
 	}
 }
+
+// sendWait 将消息放入发送通道，通道已满时等待写协程写出，直到连接关闭
+// 可靠投递模式下消息总会进入待确认列表，与 Send 相同
+func (c *Connection) sendWait(data []byte) error {
+	if c.reliable != nil {
+		return c.Send(data)
+	}
+	select {
+	case c.send <- data:
+		c.queued.Add(1)
+		return nil
+	case <-c.closeChan:
+		return ErrConnectionClosed
+	}
+}
+
+// Flush 等待调用前已放入发送通道的帧全部写出，包括此前调用 Engine.Broadcast 广播的消息
+// 超时或连接关闭时返回false
+func (c *Connection) Flush(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// 广播由主循环放入各连接的发送通道，先等待主循环处理完此前的广播
+	done := make(chan struct{})
+	select {
+	case c.engine.flush <- done:
+	case <-timer.C:
+		return false
+	}
+	select {
+	case <-done:
+	case <-timer.C:
+		return false
+	}
+
+	target := c.queued.Load()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for c.written.Load() < target {
+		select {
+		case <-ticker.C:
+		case <-c.closeChan:
+			return false
+		case <-timer.C:
+			return false
+		}
+	}
+	return true
+}
+
+// Close 使用指定的关闭码和原因断开连接
+func (c *Connection) Close(code int, reason string) {
+	c.closeWithReason(code, reason)
+}
@@ -1,7 +1,5 @@
 package Nexus
 
-import "log"
-
 // Context 表示一个请求上下文
 type Context struct {
 	Request    *ReqMessage
@@ -13,12 +11,18 @@ type Context struct {
 	exit       bool
 	index      int8
 	handlers   HandlerFuncList
+	fullPath   string
+	routeMeta  *RouteMeta
+	span       *Span
 }
 
 // NewContext 创建一个新的上下文
 func NewContext(conn *Connection) *Context {
+	// 消息头不能与默认模板共用，否则并发解析请求时会写入同一个map
 	var defaultReqMessage = DefaultReqMessage
+	defaultReqMessage.Header = DefaultReqMessage.Header.clone()
 	var defaultResMessage = DefaultResMessage
+	defaultResMessage.Header = DefaultResMessage.Header.clone()
 	return &Context{
 		Request:    &defaultReqMessage,
 		Response:   &defaultResMessage,
@@ -56,6 +60,33 @@ func (c *Context) JSON(Status status, data N) {
 	c.Exit()
 }
 
+// FullPath 返回匹配到的路由注册时的完整路径，如 /users/:id，未匹配到路由时为空
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// RouteMeta 返回匹配到的路由的元数据，未匹配到路由或未设置元数据时返回零值
+func (c *Context) RouteMeta() RouteMeta {
+	if c.routeMeta == nil {
+		return RouteMeta{}
+	}
+	return *c.routeMeta
+}
+
+// Span 返回处理当前消息的追踪跨度，未启用追踪时返回nil
+// 在处理函数中调用其他服务时，可通过 ContextWithSpan 把跨度传给客户端以延续追踪
+func (c *Context) Span() *Span {
+	return c.span
+}
+
+// Param 返回路由参数的值，不存在时返回空字符串
+func (c *Context) Param(key string) string {
+	if c.Request == nil {
+		return ""
+	}
+	return c.Request.Params.ByName(key)
+}
+
 // Get 获取上下文中的键值
 func (c *Context) Get(key string) any {
 	return c.Keys[key]
@@ -118,18 +149,18 @@ func (c *Context) Exit() {
 // Send 发送响应
 func (c *Context) Send(data []byte) {
 	if c.connection != nil {
-		select {
-		case c.connection.send <- data:
-			// 已发送
-		default:
-			// 如果通道已满，记录错误
-			if c.connection.engine.config.LogConfig.Debug {
-				log.Printf("[ERROR] Send channel full, message discarded")
-			}
+		if err := c.connection.Send(data); err != nil {
+			// 如果通道已满或连接已关闭，记录错误
+			c.connection.engine.log(LogConnection).Warn("send failed, message discarded", "conn", c.connection.id, "error", err)
 		}
 	}
 }
 
+// Connection 返回当前请求所在的连接
+func (c *Context) Connection() *Connection {
+	return c.connection
+}
+
 // Error 添加错误到上下文
 func (c *Context) Error(err error) {
 	if err != nil {
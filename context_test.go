@@ -0,0 +1,31 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestNewContextHeadersNotShared(t *testing.T) {
+	var wg sync.WaitGroup
+	contexts := make([]*Context, 8)
+	for i := range contexts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := NewContext(nil)
+			json.Unmarshal([]byte(`{"header":{"n":`+string(rune('0'+i))+`}}`), &c.Request)
+			contexts[i] = c
+		}(i)
+	}
+	wg.Wait()
+
+	for i, c := range contexts {
+		if n, _ := c.Request.Header["n"].(float64); int(n) != i {
+			t.Fatalf("context %d header n = %v", i, c.Request.Header["n"])
+		}
+	}
+	if len(DefaultReqMessage.Header) != 0 || len(DefaultResMessage.Header) != 0 {
+		t.Fatal("parsing a request modified the default message headers")
+	}
+}
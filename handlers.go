@@ -3,7 +3,6 @@ package Nexus
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 )
 
@@ -20,13 +19,10 @@ var DefaultHandlerFuncList = HandlerFuncList{DefaultHandler404Handler}
 func handleMessage(message []byte, conn *Connection, e *Engine) {
 	start := time.Now()
 	var c = NewContext(conn)
-	var requestID string
 
 	// 解析请求消息
 	if err := json.Unmarshal(message, &c.Request); err != nil {
-		if e.config.LogConfig.Debug {
-			log.Printf("[ERROR] Failed to parse message: %v", err)
-		}
+		e.log(LogRouter).Warn("invalid message", "conn", conn.id, "error", err)
 		// 如果无法解析消息，返回500错误
 		c.Response = &ResMessage{
 			Header: DefaultHeader,
@@ -40,24 +36,129 @@ func handleMessage(message []byte, conn *Connection, e *Engine) {
 		return
 	}
 
-	requestID = c.Request.ID
+	e.serveContext(c, len(message), start)
+}
+
+// ServeContext 按处理网络消息的流程处理已构造好请求的上下文，并把响应发送给上下文关联的连接
+// 与 HandleContext 不同，该方法会处理会话恢复、确认和订阅等系统消息，并记录追踪、访问日志和指标
+// 上下文必须关联连接，可以使用 ServeVirtualConn 创建不注册到Engine的连接
+func (e *Engine) ServeContext(c *Context) {
+	assert1(c.connection != nil, "ServeContext requires a context with a connection")
+	start := time.Now()
+	requestSize := 0
+	if e.config.LogConfig.AccessLog {
+		if data, err := json.Marshal(c.Request); err == nil {
+			requestSize = len(data)
+		}
+	}
+	e.serveContext(c, requestSize, start)
+}
+
+// serveContext 分发已解析的请求并发送响应
+func (e *Engine) serveContext(c *Context, requestSize int, start time.Time) {
+	conn := c.connection
+
+	// 系统消息不经过路由
+	switch {
+	case c.Request.Method == RESUME && c.Request.Path == SessionPath:
+		e.resumeSession(c)
+		sendResponse(c, conn, e)
+		return
+	case c.Request.Method == ACK && c.Request.Path == ReliablePath:
+		// 确认消息不需要响应
+		e.handleAck(c)
+		return
+	}
+
+	// 创建追踪跨度，请求头中带有traceparent时延续上游的追踪
+	if exporter := e.config.TracingConfig.Exporter; exporter != nil {
+		c.span = startSpan(c.Request.Method+" "+c.Request.Path, SpanKindServer, traceparentFromHeader(c.Request.Header), exporter)
+		defer e.finishSpan(c)
+	}
+
+	// 路由分发并执行处理链，订阅请求成功后还会加入或退出主题
+	e.dispatch(c)
 
-	// 记录访问日志
+	// 发送响应
+	responseSize := sendResponse(c, conn, e)
+
+	// 记录处理时间
+	elapsed := time.Since(start)
 	if e.config.LogConfig.AccessLog {
-		log.Printf("[ACCESS] %s %s %s", requestID, c.Request.Method, c.Request.Path)
+		e.logAccess(c, requestSize, responseSize, elapsed)
 	}
+	e.metrics.observeRequest(c.Request.Method, c.fullPath, c.Response.Status, elapsed)
+	e.log(LogRouter).Debug("request processed",
+		"request_id", c.Request.ID,
+		"conn", conn.id,
+		"method", c.Request.Method,
+		"route", c.fullPath,
+		"status", int(c.Response.Status),
+		"latency", elapsed,
+	)
+}
 
+// dispatch 执行请求的处理链，处理函数panic时返回500并通知错误钩子，避免整个进程退出
+func (e *Engine) dispatch(c *Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+			e.log(LogRouter).Error("handler panic", "request_id", c.Request.ID, "conn", c.connection.id, "error", err)
+			e.fireError(c.connection, err)
+			c.JSON(StatusInternalServerError, N{"error": "Internal Server Error"})
+		}
+	}()
+
+	switch c.Request.Method {
+	case SUBSCRIBE, UNSUBSCRIBE:
+		e.handleSubscription(c)
+	default:
+		e.HandleContext(c)
+	}
+}
+
+// finishSpan 记录路由和响应状态后结束请求的追踪跨度
+// 跨度名称与指标标签一致使用路由和已知方法，原始路径只记录在属性中
+func (e *Engine) finishSpan(c *Context) {
+	span := c.span
+	route := c.fullPath
+	if route == "" {
+		route = unmatchedRoute
+	}
+	span.Name = metricMethod(c.Request.Method) + " " + route
+	span.SetAttribute("nexus.request_id", c.Request.ID)
+	span.SetAttribute("nexus.method", c.Request.Method)
+	span.SetAttribute("nexus.path", c.Request.Path)
+	span.SetAttribute("nexus.route", route)
+	if c.connection != nil {
+		span.SetAttribute("nexus.conn", c.connection.id)
+	}
+	if c.Response != nil {
+		span.SetAttribute("nexus.status", int(c.Response.Status))
+		if c.Response.Status >= StatusInternalServerError {
+			span.SetError(fmt.Errorf("status %d", c.Response.Status))
+		}
+	}
+	for _, err := range c.Errors {
+		span.SetError(*err)
+	}
+	span.Finish()
+}
+
+// HandleContext 对上下文执行路由匹配、中间件和处理函数链，并补全默认响应
+// 该方法不会把响应发送给客户端，可用于在进程内直接驱动路由
+func (e *Engine) HandleContext(c *Context) {
 	// 路由分发
-	handlers, params, ok := e.ParsePath(c.Request.Method, c.Request.Path)
+	handlers, params, fullPath, ok := e.lookup(c.Request.Method, c.Request.Path)
 	if !ok {
 		// 未找到路由，调用404处理函数
-		if e.config.LogConfig.Debug {
-			log.Printf("[DEBUG] Route not found: %s %s", c.Request.Method, c.Request.Path)
-		}
+		e.log(LogRouter).Debug("route not found", "request_id", c.Request.ID, "method", c.Request.Method, "path", c.Request.Path)
 		c.handlers = HandlerFuncList{DefaultHandler404Handler}
 	} else {
 		// 设置路由参数和请求头
 		c.Request.Params = params
+		c.fullPath = fullPath
+		c.routeMeta = e.routeMetaOf(routeKey{method: c.Request.Method, route: fullPath})
 		c.Header = c.Request.Header
 		c.handlers = handlers
 	}
@@ -68,25 +169,16 @@ func handleMessage(message []byte, conn *Connection, e *Engine) {
 	// 如果没有设置响应，设置默认响应
 	if c.Response == nil || c.Response.ID == "" {
 		c.Response = &ResMessage{
-			ID:     requestID,
+			ID:     c.Request.ID,
 			Header: c.Header,
 			Status: StatusOK,
 			Body:   N{},
 		}
 	}
-
-	// 发送响应
-	sendResponse(c, conn, e)
-
-	// 记录处理时间
-	if e.config.LogConfig.Debug {
-		elapsed := time.Since(start)
-		log.Printf("[DEBUG] Request %s processed in %v", requestID, elapsed)
-	}
 }
 
-// sendResponse 发送响应到客户端
-func sendResponse(c *Context, conn *Connection, e *Engine) {
+// sendResponse 发送响应到客户端，返回响应消息的字节数
+func sendResponse(c *Context, conn *Connection, e *Engine) int {
 	// 确保响应ID与请求ID一致
 	if c.Response.ID == "" {
 		c.Response.ID = c.Request.ID
@@ -100,25 +192,20 @@ func sendResponse(c *Context, conn *Connection, e *Engine) {
 	// 序列化响应
 	respBytes, err := json.Marshal(c.Response)
 	if err != nil {
-		if e.config.LogConfig.Debug {
-			log.Printf("[ERROR] Failed to serialize response: %v", err)
-		}
+		e.log(LogRouter).Warn("failed to serialize response", "request_id", c.Request.ID, "error", err)
 		// 如果无法序列化响应，返回简单的错误响应
 		respBytes = []byte(fmt.Sprintf(`{"id":"%s","status":%d,"header":{"Content-Type":"application/json"},"body":{"error":"Internal Server Error"}}`,
 			c.Request.ID, StatusInternalServerError))
 	}
 
 	// 发送响应
-	select {
-	case conn.send <- respBytes:
-		// 消息已发送
-	default:
+	if err := conn.enqueue(respBytes); err != nil {
 		// 发送通道已满，关闭连接
-		if e.config.LogConfig.Debug {
-			log.Printf("[WARN] Send channel full, closing connection")
-		}
+		e.metrics.drop()
+		e.log(LogConnection).Warn("send channel full, closing connection", "conn", conn.id)
 		conn.close()
 	}
+	return len(respBytes)
 }
 
 // DefaultHandler404Handler 默认404处理函数
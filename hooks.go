@@ -0,0 +1,76 @@
+package Nexus
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrHandlerPanic 处理函数panic，错误钩子收到的错误包装了该错误和panic的值
+var ErrHandlerPanic = errors.New("handler panic")
+
+// ConnectHook 在连接建立后、注册到Engine之前调用
+// r为WebSocket升级请求，通过 ServeConn 接入的连接为nil；返回错误将拒绝该连接
+type ConnectHook func(c *Connection, r *http.Request) error
+
+// DisconnectHook 在连接断开后调用，code和reason为关闭码和原因
+type DisconnectHook func(c *Connection, code int, reason string)
+
+// ErrorHook 在传输层出错或处理函数panic时调用，升级失败时c为nil
+type ErrorHook func(c *Connection, err error)
+
+// OnConnect 注册连接钩子
+func (e *Engine) OnConnect(hooks ...ConnectHook) {
+	e.hooksMu.Lock()
+	defer e.hooksMu.Unlock()
+	e.connectHooks = append(e.connectHooks, hooks...)
+}
+
+// OnDisconnect 注册断开钩子
+func (e *Engine) OnDisconnect(hooks ...DisconnectHook) {
+	e.hooksMu.Lock()
+	defer e.hooksMu.Unlock()
+	e.disconnectHooks = append(e.disconnectHooks, hooks...)
+}
+
+// OnError 注册错误钩子
+func (e *Engine) OnError(hooks ...ErrorHook) {
+	e.hooksMu.Lock()
+	defer e.hooksMu.Unlock()
+	e.errorHooks = append(e.errorHooks, hooks...)
+}
+
+// fireConnect 依次执行连接钩子，返回第一个错误
+func (e *Engine) fireConnect(c *Connection, r *http.Request) error {
+	e.hooksMu.RLock()
+	hooks := e.connectHooks
+	e.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook(c, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fireDisconnect 依次执行断开钩子
+func (e *Engine) fireDisconnect(c *Connection, code int, reason string) {
+	e.hooksMu.RLock()
+	hooks := e.disconnectHooks
+	e.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(c, code, reason)
+	}
+}
+
+// fireError 依次执行错误钩子
+func (e *Engine) fireError(c *Connection, err error) {
+	e.hooksMu.RLock()
+	hooks := e.errorHooks
+	e.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(c, err)
+	}
+}
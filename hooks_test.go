@@ -0,0 +1,128 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookRecorder 按调用顺序记录钩子事件
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *hookRecorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *hookRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestLifecycleHookOrder(t *testing.T) {
+	e := New()
+	var rec hookRecorder
+	var registered bool
+	e.OnConnect(
+		func(c *Connection, r *http.Request) error {
+			_, registered = e.Connection(c.ID())
+			rec.add("connect 1")
+			return nil
+		},
+		func(c *Connection, r *http.Request) error {
+			rec.add("connect 2")
+			return nil
+		},
+	)
+	e.OnDisconnect(
+		func(c *Connection, code int, reason string) { rec.add("disconnect 1 " + reason) },
+		func(c *Connection, code int, reason string) { rec.add("disconnect 2 " + reason) },
+	)
+
+	conn, _ := newTestConn(t, e)
+	if registered {
+		t.Fatal("connection registered before connect hooks finished")
+	}
+	e.Kick(conn.ID(), "bye")
+	waitFor(t, func() bool { return len(rec.get()) == 4 })
+
+	want := []string{"connect 1", "connect 2", "disconnect 1 bye", "disconnect 2 bye"}
+	for i, event := range rec.get() {
+		if event != want[i] {
+			t.Fatalf("events = %v, want %v", rec.get(), want)
+		}
+	}
+}
+
+func TestConnectHookRejects(t *testing.T) {
+	e := New()
+	var rec hookRecorder
+	reject := errors.New("not allowed")
+	e.OnConnect(
+		func(c *Connection, r *http.Request) error { return reject },
+		func(c *Connection, r *http.Request) error {
+			rec.add("second connect hook")
+			return nil
+		},
+	)
+	e.OnDisconnect(func(c *Connection, code int, reason string) { rec.add("disconnect") })
+
+	client, server := NewPipe()
+	defer client.Close()
+	if conn, err := e.ServeConn(server); !errors.Is(err, reject) || conn != nil {
+		t.Fatalf("ServeConn = %v, %v, want rejection", conn, err)
+	}
+	var ce *CloseError
+	if _, err := readTestFrame(t, client); !errors.As(err, &ce) || ce.Code != ClosePolicyViolation || ce.Reason != reject.Error() {
+		t.Fatalf("ReadFrame error = %v, want policy violation with the hook's error", err)
+	}
+	// 等待主循环处理完此前的所有注册
+	done := make(chan struct{})
+	e.flush <- done
+	<-done
+	if conns := e.Connections(); len(conns) != 0 {
+		t.Fatalf("Connections = %v, want rejected connection not registered", conns)
+	}
+	if events := rec.get(); len(events) != 0 {
+		t.Fatalf("events = %v, want no further hooks for a rejected connection", events)
+	}
+}
+
+func TestErrorHookOnHandlerPanic(t *testing.T) {
+	e := New()
+	e.GET("/panic", func(c *Context) { panic("boom") })
+	errs := make(chan error, 1)
+	var hookConn *Connection
+	e.OnError(func(c *Connection, err error) {
+		hookConn = c
+		errs <- err
+	})
+	conn, client := newTestConn(t, e)
+
+	req, _ := json.Marshal(ReqMessage{ID: "1", Method: GET, Path: "/panic"})
+	client.WriteFrame(req)
+	res, _ := readUntil(t, client, func(m ResMessage) bool { return m.ID == "1" })
+	if res.Status != StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", res.Status)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrHandlerPanic) || hookConn != conn {
+			t.Fatalf("error hook = %v on %v, want handler panic on the connection", err, hookConn)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error hook not called for handler panic")
+	}
+	// 连接在处理函数panic后仍然可用
+	if _, ok := e.Connection(conn.ID()); !ok {
+		t.Fatal("connection closed after handler panic")
+	}
+}
@@ -0,0 +1,103 @@
+package Nexus
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// 日志子系统名称，可在 LogConfig.Levels 中为每个子系统单独设置日志级别
+const (
+	LogEngine     = "engine"
+	LogConnection = "connection"
+	LogRouter     = "router"
+	LogCluster    = "cluster"
+	LogSession    = "session"
+	LogAccess     = "access"
+	LogClient     = "client"
+)
+
+// levelHandler 按子系统级别过滤日志的slog.Handler
+type levelHandler struct {
+	level slog.Leveler
+	slog.Handler
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, Handler: h.Handler.WithGroup(name)}
+}
+
+// newHandler 按格式创建输出所有级别的slog.Handler，由外层的levelHandler负责过滤
+func newHandler(format string, out io.Writer) slog.Handler {
+	if out == nil {
+		out = os.Stderr
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if format == "json" {
+		return slog.NewJSONHandler(out, opts)
+	}
+	return slog.NewTextHandler(out, opts)
+}
+
+// defaultLevel 未单独设置级别时使用的级别
+// 调试模式输出所有日志，否则输出警告和错误，发送队列已满、集群消息失败等问题默认可见
+func defaultLevel(debug bool) slog.Level {
+	if debug {
+		return slog.LevelDebug
+	}
+	return slog.LevelWarn
+}
+
+// handler 返回所有子系统共用的slog.Handler，同一输出只由一个Handler串行写入
+func (cfg LogConfig) handler() slog.Handler {
+	if cfg.Logger != nil {
+		return cfg.Logger.Handler()
+	}
+	return newHandler(cfg.Format, cfg.Output)
+}
+
+// logger 基于共用的Handler返回子系统的日志记录器
+// 配置了Logger且未单独设置该子系统的级别时，由Logger自身决定输出哪些级别
+func (cfg LogConfig) logger(base slog.Handler, subsystem string) *slog.Logger {
+	var level slog.Leveler
+	if cfg.Logger == nil {
+		level = defaultLevel(cfg.Debug)
+		if subsystem == LogAccess {
+			// 访问日志由 AccessLog 开关控制
+			level = slog.LevelInfo
+		}
+	}
+	if l, ok := cfg.Levels[subsystem]; ok {
+		level = l
+	}
+
+	h := base
+	if level != nil {
+		h = &levelHandler{level: level, Handler: h}
+	}
+	return slog.New(h).With("subsystem", subsystem)
+}
+
+// newLoggers 为所有子系统创建共用同一Handler的日志记录器
+func newLoggers(cfg LogConfig) map[string]*slog.Logger {
+	base := cfg.handler()
+	loggers := make(map[string]*slog.Logger)
+	for _, subsystem := range []string{LogEngine, LogConnection, LogRouter, LogCluster, LogSession, LogAccess} {
+		loggers[subsystem] = cfg.logger(base, subsystem)
+	}
+	return loggers
+}
+
+// log 返回Engine子系统的日志记录器
+func (e *Engine) log(subsystem string) *slog.Logger {
+	return e.loggers[subsystem]
+}
@@ -0,0 +1,60 @@
+package Nexus
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       LogConfig
+		subsystem string
+		level     slog.Level
+		want      bool
+	}{
+		{"warn visible by default", LogConfig{}, LogConnection, slog.LevelWarn, true},
+		{"info hidden by default", LogConfig{}, LogCluster, slog.LevelInfo, false},
+		{"debug mode", LogConfig{Debug: true}, LogEngine, slog.LevelDebug, true},
+		{"access log info", LogConfig{}, LogAccess, slog.LevelInfo, true},
+		{"subsystem level", LogConfig{Levels: map[string]slog.Level{LogRouter: slog.LevelError}}, LogRouter, slog.LevelWarn, false},
+	}
+	for _, tt := range tests {
+		l := tt.cfg.logger(tt.cfg.handler(), tt.subsystem)
+		if got := l.Enabled(context.Background(), tt.level); got != tt.want {
+			t.Errorf("%s: Enabled(%v) = %v, want %v", tt.name, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestLoggersShareHandler(t *testing.T) {
+	// bytes.Buffer 不是并发安全的，共用一个Handler时写入被串行化
+	var buf bytes.Buffer
+	loggers := newLoggers(LogConfig{Output: &buf})
+
+	var wg sync.WaitGroup
+	for _, subsystem := range []string{LogEngine, LogCluster, LogConnection} {
+		wg.Add(1)
+		go func(l *slog.Logger) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l.Warn("message")
+			}
+		}(loggers[subsystem])
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 150 {
+		t.Fatalf("wrote %d lines, want 150", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "msg=message subsystem=") {
+			t.Fatalf("interleaved line %q", line)
+		}
+	}
+}
@@ -77,6 +77,15 @@ var DefaultHeader = header{
 	"User-Agent":   "Nexus",
 }
 
+// clone 返回消息头的副本
+func (h header) clone() header {
+	c := make(header, len(h))
+	for k, v := range h {
+		c[k] = v
+	}
+	return c
+}
+
 // Bytes 将ReqMessage序列化为JSON字节数组
 func (r *ReqMessage) Bytes() []byte {
 	if r.Timestamp.IsZero() {
@@ -109,7 +118,7 @@ func NewRequest(method, path string, body any) *ReqMessage {
 		ID:        GenerateUniqueString(),
 		Method:    method,
 		Path:      path,
-		Header:    DefaultHeader,
+		Header:    DefaultHeader.clone(),
 		Body:      body,
 		Timestamp: time.Now(),
 	}
@@ -120,7 +129,7 @@ func NewResponse(id string, status status, body any) *ResMessage {
 	return &ResMessage{
 		ID:        id,
 		Status:    status,
-		Header:    DefaultHeader,
+		Header:    DefaultHeader.clone(),
 		Body:      body,
 		Timestamp: time.Now(),
 	}
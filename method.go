@@ -5,4 +5,16 @@ const (
 	POST   string = "POST"
 	PUT    string = "PUT"
 	DELETE string = "DELETE"
+	// SUBSCRIBE 订阅主题，路由中的处理函数决定是否允许订阅
+	SUBSCRIBE string = "SUBSCRIBE"
+	// UNSUBSCRIBE 取消订阅主题
+	UNSUBSCRIBE string = "UNSUBSCRIBE"
+)
+
+// 系统方法，由框架内部处理，不经过路由
+const (
+	// RESUME 在重连后恢复会话
+	RESUME string = "RESUME"
+	// ACK 确认收到可靠投递的推送消息
+	ACK string = "ACK"
 )
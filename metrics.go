@@ -0,0 +1,249 @@
+package Nexus
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMetricsBuckets 请求耗时直方图的默认分桶，单位为秒，与Prometheus客户端库一致
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// unmatchedRoute 未匹配到路由的请求在指标中使用的路由标签，避免原始路径导致标签数量无限增长
+const unmatchedRoute = "unmatched"
+
+// otherMethod 未知方法的请求在指标中使用的方法标签，请求方法由客户端决定，不能直接作为标签
+const otherMethod = "other"
+
+// metricMethod 返回请求方法的指标标签，路由方法以外的方法统一为 otherMethod
+func metricMethod(method string) string {
+	switch method {
+	case GET, POST, PUT, DELETE, SUBSCRIBE, UNSUBSCRIBE:
+		return method
+	}
+	return otherMethod
+}
+
+// metrics 收集引擎的运行指标，未启用指标时为nil，所有方法都可以在nil上调用
+type metrics struct {
+	buckets []float64
+
+	connects         atomic.Uint64
+	disconnects      atomic.Uint64
+	messagesReceived atomic.Uint64
+	messagesSent     atomic.Uint64
+	bytesReceived    atomic.Uint64
+	bytesSent        atomic.Uint64
+	dropped          atomic.Uint64
+
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	latency  map[routeKey]*histogram
+}
+
+// routeKey 按方法和路由完整路径区分的指标标签
+type routeKey struct {
+	method string
+	route  string
+}
+
+// requestKey 请求计数的指标标签
+type requestKey struct {
+	routeKey
+	status status
+}
+
+// histogram 累积分桶的耗时直方图
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// newMetrics 创建指标收集器，未指定分桶时使用 DefaultMetricsBuckets
+func newMetrics(buckets []float64) *metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &metrics{
+		buckets:  sorted,
+		requests: make(map[requestKey]uint64),
+		latency:  make(map[routeKey]*histogram),
+	}
+}
+
+func (m *metrics) connected() {
+	if m != nil {
+		m.connects.Add(1)
+	}
+}
+
+func (m *metrics) disconnected() {
+	if m != nil {
+		m.disconnects.Add(1)
+	}
+}
+
+func (m *metrics) received(n int) {
+	if m != nil {
+		m.messagesReceived.Add(1)
+		m.bytesReceived.Add(uint64(n))
+	}
+}
+
+func (m *metrics) sent(n int) {
+	if m != nil {
+		m.messagesSent.Add(1)
+		m.bytesSent.Add(uint64(n))
+	}
+}
+
+func (m *metrics) drop() {
+	if m != nil {
+		m.dropped.Add(1)
+	}
+}
+
+// observeRequest 记录一次请求的状态码和耗时，route为路由注册时的完整路径
+func (m *metrics) observeRequest(method, route string, code status, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	if route == "" {
+		route = unmatchedRoute
+	}
+	rk := routeKey{method: metricMethod(method), route: route}
+	seconds := elapsed.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{routeKey: rk, status: code}]++
+	h, ok := m.latency[rk]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latency[rk] = h
+	}
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// MetricsHandler 返回以Prometheus文本格式输出指标的http.Handler，未启用指标时返回404
+func (e *Engine) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e.metrics == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e.writeMetrics(w)
+	})
+}
+
+// writeMetrics 输出所有指标
+func (e *Engine) writeMetrics(w io.Writer) {
+	m := e.metrics
+
+	// 连接数和发送队列深度在输出时统计
+	e.mu.Lock()
+	active := len(e.connections)
+	queued := 0
+	for conn := range e.connections {
+		queued += len(conn.send)
+	}
+	e.mu.Unlock()
+
+	writeMetric(w, "nexus_connections_active", "gauge", "Number of currently open connections.", float64(active))
+	writeMetric(w, "nexus_connections_total", "counter", "Total number of accepted connections.", float64(m.connects.Load()))
+	writeMetric(w, "nexus_disconnections_total", "counter", "Total number of closed connections.", float64(m.disconnects.Load()))
+	writeMetric(w, "nexus_messages_received_total", "counter", "Total number of frames received from clients.", float64(m.messagesReceived.Load()))
+	writeMetric(w, "nexus_messages_sent_total", "counter", "Total number of frames written to clients.", float64(m.messagesSent.Load()))
+	writeMetric(w, "nexus_received_bytes_total", "counter", "Total number of bytes received from clients.", float64(m.bytesReceived.Load()))
+	writeMetric(w, "nexus_sent_bytes_total", "counter", "Total number of bytes written to clients.", float64(m.bytesSent.Load()))
+	writeMetric(w, "nexus_send_queue_depth", "gauge", "Number of frames waiting in all connection send queues.", float64(queued))
+	writeMetric(w, "nexus_messages_dropped_total", "counter", "Total number of messages dropped because a send queue was full or delivery failed.", float64(m.dropped.Load()))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		requestKeys = append(requestKeys, k)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	fmt.Fprintln(w, "# HELP nexus_requests_total Total number of handled requests by route and status.")
+	fmt.Fprintln(w, "# TYPE nexus_requests_total counter")
+	for _, k := range requestKeys {
+		fmt.Fprintf(w, "nexus_requests_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			quoteLabel(k.method), quoteLabel(k.route), k.status, m.requests[k])
+	}
+
+	routeKeys := make([]routeKey, 0, len(m.latency))
+	for k := range m.latency {
+		routeKeys = append(routeKeys, k)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		if routeKeys[i].route != routeKeys[j].route {
+			return routeKeys[i].route < routeKeys[j].route
+		}
+		return routeKeys[i].method < routeKeys[j].method
+	})
+	fmt.Fprintln(w, "# HELP nexus_request_duration_seconds Request handling latency by route.")
+	fmt.Fprintln(w, "# TYPE nexus_request_duration_seconds histogram")
+	for _, k := range routeKeys {
+		h := m.latency[k]
+		labels := fmt.Sprintf("method=%s,route=%s", quoteLabel(k.method), quoteLabel(k.route))
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "nexus_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "nexus_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "nexus_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "nexus_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
+
+// writeMetric 输出一个不带标签的指标
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatFloat(value))
+}
+
+// formatFloat 按Prometheus文本格式输出数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelEscaper 转义标签值中的反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel 返回加上双引号并转义后的标签值
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,82 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsOutput(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MetricsConfig.Enabled = true
+	cfg.MetricsConfig.Buckets = []float64{1, 0.1}
+	e := NewWithConfig(cfg)
+	e.GET("/users/:id", func(c *Context) { c.JSON(StatusOK, N{"id": c.Param("id")}) })
+
+	_, client := newTestConn(t, e)
+	requests := []ReqMessage{
+		{ID: "1", Method: GET, Path: "/users/1"},
+		{ID: "2", Method: GET, Path: "/users/2"},
+		{ID: "3", Method: GET, Path: "/missing"},
+		{ID: "4", Method: "BREW-a1b2", Path: "/users/3"},
+	}
+	for _, r := range requests {
+		req, _ := json.Marshal(r)
+		if err := client.WriteFrame(req); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+		readUntil(t, client, func(m ResMessage) bool { return m.ID == r.ID })
+	}
+
+	rec := httptest.NewRecorder()
+	e.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	tests := []string{
+		"# TYPE nexus_connections_active gauge\nnexus_connections_active 1\n",
+		"nexus_connections_total 1\n",
+		"nexus_messages_received_total 4\n",
+		`nexus_requests_total{method="GET",route="/users/:id",status="200"} 2` + "\n",
+		`nexus_requests_total{method="GET",route="unmatched",status="404"} 1` + "\n",
+		`nexus_requests_total{method="other",route="unmatched",status="404"} 1` + "\n",
+		`nexus_request_duration_seconds_bucket{method="GET",route="/users/:id",le="0.1"} 2` + "\n",
+		`nexus_request_duration_seconds_bucket{method="GET",route="/users/:id",le="1"} 2` + "\n",
+		`nexus_request_duration_seconds_bucket{method="GET",route="/users/:id",le="+Inf"} 2` + "\n",
+		`nexus_request_duration_seconds_count{method="GET",route="/users/:id"} 2` + "\n",
+	}
+	for _, want := range tests {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q\n%s", want, body)
+		}
+	}
+	for _, raw := range []string{"/missing", "BREW"} {
+		if strings.Contains(body, raw) {
+			t.Errorf("metrics output contains raw request value %q\n%s", raw, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	New().MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("disabled metrics status = %d, want 404", rec.Code)
+	}
+}
+
+func TestMetricsFormatting(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{formatFloat(0.005), "0.005"},
+		{formatFloat(10), "10"},
+		{formatFloat(math.Inf(1)), "+Inf"},
+		{quoteLabel("/a"), `"/a"`},
+		{quoteLabel(`a"b\c` + "\n"), `"a\"b\\c\n"`},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %s, want %s", tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package nexustest 提供在进程内测试Nexus路由和中间件的工具，无需打开网络连接
+package nexustest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gitoo.icu/Nexus/Nexus"
+)
+
+// FlushTimeout 等待已发送帧写出的最长时间
+var FlushTimeout = 5 * time.Second
+
+// ErrFlushTimeout 在 FlushTimeout 内没有等到推送的帧全部写出
+var ErrFlushTimeout = errors.New("nexustest: flush timed out")
+
+// Result 表示一次进程内请求的处理结果
+type Result struct {
+	// Context 处理请求使用的上下文
+	Context *Nexus.Context
+	// Response 处理链产生的响应
+	Response *Nexus.ResMessage
+	// Frames 处理期间通过 Context.Send 等方式推送到该连接的帧
+	Frames [][]byte
+	// Err 等待推送的帧写出超时时为 ErrFlushTimeout
+	Err error
+}
+
+// Do 构造上下文并按处理网络消息的流程执行请求，包括订阅等系统消息、追踪、访问日志和指标
+// 请求在一个不注册到Engine的虚拟连接上执行，不会触发连接钩子，也不会收到会话令牌、离线消息和广播
+// req 不会被修改，推送到该连接的帧记录在结果中，响应本身不计入 Frames
+func Do(e *Nexus.Engine, req *Nexus.ReqMessage) *Result {
+	r := *req
+	if r.ID == "" {
+		r.ID = Nexus.GenerateUniqueString()
+	}
+
+	// 复制请求头，避免处理函数修改调用方或共享的默认请求头
+	r.Header = make(map[string]any, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	fc := newRecordConn()
+	conn := e.ServeVirtualConn(fc)
+	defer conn.Close(Nexus.CloseNormalClosure, "")
+
+	c := Nexus.NewContext(conn)
+	c.Request = &r
+	e.ServeContext(c)
+
+	result := &Result{
+		Context:  c,
+		Response: c.Response,
+	}
+	if !conn.Flush(FlushTimeout) {
+		result.Err = ErrFlushTimeout
+	}
+
+	// 去掉发送给该连接的响应帧，只保留推送
+	frames := fc.snapshot()
+	if resp, err := json.Marshal(c.Response); err == nil {
+		for i, frame := range frames {
+			if bytes.Equal(frame, resp) {
+				frames = append(frames[:i], frames[i+1:]...)
+				break
+			}
+		}
+	}
+	result.Frames = frames
+	return result
+}
+
+// Recorder 是注册在Engine上的虚拟连接，记录发送给它的所有帧（包括广播）
+type Recorder struct {
+	engine *Nexus.Engine
+	conn   *Nexus.Connection
+	fc     *recordConn
+}
+
+// NewRecorder 创建一个记录器并注册到Engine，连接钩子拒绝时返回错误
+func NewRecorder(e *Nexus.Engine) (*Recorder, error) {
+	fc := newRecordConn()
+	conn, err := e.ServeConn(fc)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		engine: e,
+		conn:   conn,
+		fc:     fc,
+	}, nil
+}
+
+// Connection 返回记录器对应的连接
+func (r *Recorder) Connection() *Nexus.Connection {
+	return r.conn
+}
+
+// Frames 返回目前已记录的帧
+func (r *Recorder) Frames() [][]byte {
+	return r.fc.snapshot()
+}
+
+// Reset 清空已记录的帧
+func (r *Recorder) Reset() {
+	r.fc.mu.Lock()
+	r.fc.frames = nil
+	r.fc.mu.Unlock()
+}
+
+// Flush 等待此前调用的 Engine.Broadcast 和发送给记录器连接的帧全部写入记录器
+// 不会向其他连接或集群中的其他节点发送任何消息
+func (r *Recorder) Flush() bool {
+	return r.conn.Flush(FlushTimeout)
+}
+
+// Close 断开记录器对应的连接
+func (r *Recorder) Close() {
+	r.fc.Close()
+}
+
+// recordConn 把写入的帧记录在内存中的FrameConn实现
+type recordConn struct {
+	mu        sync.Mutex
+	frames    [][]byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	onPong    func()
+}
+
+func newRecordConn() *recordConn {
+	return &recordConn{closed: make(chan struct{})}
+}
+
+// snapshot 返回已记录帧的副本
+func (rc *recordConn) snapshot() [][]byte {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	frames := make([][]byte, len(rc.frames))
+	copy(frames, rc.frames)
+	return frames
+}
+
+func (rc *recordConn) ReadFrame() ([]byte, error) {
+	<-rc.closed
+	return nil, Nexus.ErrPipeClosed
+}
+
+func (rc *recordConn) WriteFrame(data []byte) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	frame := make([]byte, len(data))
+	copy(frame, data)
+	rc.frames = append(rc.frames, frame)
+	return nil
+}
+
+func (rc *recordConn) Ping() error {
+	rc.mu.Lock()
+	onPong := rc.onPong
+	rc.mu.Unlock()
+	if onPong != nil {
+		onPong()
+	}
+	return nil
+}
+
+func (rc *recordConn) Close() error {
+	rc.closeOnce.Do(func() {
+		close(rc.closed)
+	})
+	return nil
+}
+
+func (rc *recordConn) CloseWithReason(code int, reason string) error {
+	return rc.Close()
+}
+
+func (rc *recordConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (rc *recordConn) SetPongHandler(h func()) {
+	rc.mu.Lock()
+	rc.onPong = h
+	rc.mu.Unlock()
+}
+
+func (rc *recordConn) RemoteAddr() string {
+	return "nexustest"
+}
@@ -0,0 +1,134 @@
+package nexustest
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitoo.icu/Nexus/Nexus"
+)
+
+func newEngine() *Nexus.Engine {
+	e := Nexus.New()
+	e.GET("/users/:id", func(c *Nexus.Context) {
+		c.Send([]byte("pushed"))
+		c.JSON(Nexus.StatusOK, Nexus.N{"id": c.Param("id")})
+	})
+	e.GET("/header", func(c *Nexus.Context) {
+		c.SetHeader("touched", true)
+		c.JSON(Nexus.StatusOK, Nexus.N{})
+	})
+	return e
+}
+
+func TestDo(t *testing.T) {
+	e := newEngine()
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantFrames int
+	}{
+		{"matched route with push", Nexus.GET, "/users/42", 200, 1},
+		{"unmatched route", Nexus.GET, "/missing", 404, 0},
+		{"unsubscribe without route", Nexus.UNSUBSCRIBE, "/topic", 200, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Do(e, &Nexus.ReqMessage{Method: tt.method, Path: tt.path, Header: map[string]any{}})
+			if res.Err != nil {
+				t.Fatalf("Err = %v", res.Err)
+			}
+			if int(res.Response.Status) != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.Response.Status, tt.wantStatus)
+			}
+			if len(res.Frames) != tt.wantFrames {
+				t.Fatalf("frames = %q, want %d frames", res.Frames, tt.wantFrames)
+			}
+		})
+	}
+}
+
+func TestDoDoesNotModifyRequest(t *testing.T) {
+	e := newEngine()
+	req := &Nexus.ReqMessage{Method: Nexus.GET, Path: "/header", Header: map[string]any{"a": 1}}
+	res := Do(e, req)
+	if req.ID != "" {
+		t.Fatalf("req.ID = %q, want unchanged", req.ID)
+	}
+	if _, ok := req.Header["touched"]; ok {
+		t.Fatal("handler modified the caller's header")
+	}
+	if res.Context.Request.ID == "" {
+		t.Fatal("request in context has no ID")
+	}
+}
+
+func TestDoSkipsConnectionHooks(t *testing.T) {
+	e := newEngine()
+	var connects, disconnects atomic.Int32
+	e.OnConnect(func(c *Nexus.Connection, r *http.Request) error {
+		connects.Add(1)
+		return errors.New("rejected")
+	})
+	e.OnDisconnect(func(c *Nexus.Connection, code int, reason string) { disconnects.Add(1) })
+
+	res := Do(e, &Nexus.ReqMessage{Method: Nexus.GET, Path: "/users/1"})
+	if res.Err != nil || res.Response.Status != Nexus.StatusOK {
+		t.Fatalf("Do = %v, %v", res.Response, res.Err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if connects.Load() != 0 || disconnects.Load() != 0 {
+		t.Fatalf("hooks fired: connect=%d disconnect=%d", connects.Load(), disconnects.Load())
+	}
+	if n := len(e.Connections()); n != 0 {
+		t.Fatalf("Do left %d registered connections", n)
+	}
+}
+
+func TestRecorderFlushOnlyReachesRecorder(t *testing.T) {
+	e := Nexus.New()
+	a, err := NewRecorder(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewRecorder(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	e.Broadcast([]byte("hello"))
+	if !a.Flush() || !b.Flush() {
+		t.Fatal("Flush timed out")
+	}
+	for name, r := range map[string]*Recorder{"a": a, "b": b} {
+		frames := r.Frames()
+		if len(frames) != 1 || string(frames[0]) != "hello" {
+			t.Fatalf("recorder %s frames = %q, want only the broadcast", name, frames)
+		}
+	}
+}
+
+func TestRecorderFlushWithReliableDelivery(t *testing.T) {
+	cfg := Nexus.DefaultConfig()
+	cfg.ReliableConfig.Enabled = true
+	e := Nexus.NewWithConfig(cfg)
+	r, err := NewRecorder(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	e.Broadcast([]byte(`{"n":1}`))
+	if !r.Flush() {
+		t.Fatal("Flush timed out in reliable mode")
+	}
+	if len(r.Frames()) != 1 {
+		t.Fatalf("frames = %q, want one reliable envelope", r.Frames())
+	}
+}
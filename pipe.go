@@ -0,0 +1,231 @@
+package Nexus
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// pipeBufferSize 内存管道每个方向的缓冲帧数
+const pipeBufferSize = 64
+
+// ErrPipeClosed 内存管道已关闭
+var ErrPipeClosed = errors.New("pipe closed")
+
+// pipeControl 内存管道中传递的控制帧
+type pipeControl int
+
+const (
+	pipePing pipeControl = iota
+	pipePong
+)
+
+// pipeConn 内存管道的一端
+type pipeConn struct {
+	in         chan []byte
+	out        chan []byte
+	closed     chan struct{}
+	peerClosed chan struct{}
+	closeOnce  sync.Once
+	closeErr   *CloseError
+	// ctrlIn、ctrlOut 传递心跳控制帧，与WebSocket一样只在读取时处理
+	ctrlIn   chan pipeControl
+	ctrlOut  chan pipeControl
+	peer     *pipeConn
+	mu       sync.Mutex
+	deadline time.Time
+	// deadlineChanged 在读取超时被修改时关闭，使阻塞中的读取重新计算超时
+	deadlineChanged chan struct{}
+	onPong          func()
+}
+
+// NewPipe 创建一对互相连接的内存帧连接
+// 写入一端的帧可以从另一端读出，适用于进程内测试和嵌入式场景
+func NewPipe() (FrameConn, FrameConn) {
+	a2b := make(chan []byte, pipeBufferSize)
+	b2a := make(chan []byte, pipeBufferSize)
+	aClosed := make(chan struct{})
+	bClosed := make(chan struct{})
+
+	a2bCtrl := make(chan pipeControl, pipeBufferSize)
+	b2aCtrl := make(chan pipeControl, pipeBufferSize)
+
+	a := &pipeConn{in: b2a, out: a2b, ctrlIn: b2aCtrl, ctrlOut: a2bCtrl, closed: aClosed, peerClosed: bClosed, deadlineChanged: make(chan struct{})}
+	b := &pipeConn{in: a2b, out: b2a, ctrlIn: a2bCtrl, ctrlOut: b2aCtrl, closed: bClosed, peerClosed: aClosed, deadlineChanged: make(chan struct{})}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func (p *pipeConn) ReadFrame() ([]byte, error) {
+	for {
+		p.mu.Lock()
+		deadline := p.deadline
+		changed := p.deadlineChanged
+		p.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timer = time.NewTimer(time.Until(deadline))
+			timeout = timer.C
+		}
+		stop := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+
+		select {
+		case data := <-p.in:
+			stop()
+			return data, nil
+		case <-p.closed:
+			stop()
+			return nil, ErrPipeClosed
+		case <-p.peerClosed:
+			stop()
+			// 对端关闭前写入的帧仍然可读
+			select {
+			case data := <-p.in:
+				return data, nil
+			default:
+				return nil, p.peer.closeError()
+			}
+		case <-timeout:
+			return nil, os.ErrDeadlineExceeded
+		case <-changed:
+			// 读取超时被修改，按新的超时时间继续等待
+			stop()
+		case ctrl := <-p.ctrlIn:
+			stop()
+			p.handleControl(ctrl)
+		}
+	}
+}
+
+func (p *pipeConn) WriteFrame(data []byte) error {
+	frame := make([]byte, len(data))
+	copy(frame, data)
+
+	select {
+	case <-p.closed:
+		return ErrPipeClosed
+	case <-p.peerClosed:
+		return ErrPipeClosed
+	default:
+	}
+
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+
+	select {
+	case p.out <- frame:
+		return nil
+	case <-p.closed:
+		return ErrPipeClosed
+	case <-p.peerClosed:
+		return ErrPipeClosed
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func (p *pipeConn) Ping() error {
+	select {
+	case <-p.closed:
+		return ErrPipeClosed
+	case <-p.peerClosed:
+		return ErrPipeClosed
+	default:
+	}
+
+	// 心跳由对端在读取时响应，对端不再读取时不会收到响应
+	select {
+	case p.ctrlOut <- pipePing:
+	default:
+		// 控制帧积压说明对端没有在读取，丢弃本次心跳
+	}
+	return nil
+}
+
+// handleControl 处理对端发来的控制帧，收到心跳时回复响应，收到响应时触发回调
+func (p *pipeConn) handleControl(ctrl pipeControl) {
+	switch ctrl {
+	case pipePing:
+		select {
+		case p.ctrlOut <- pipePong:
+		default:
+		}
+	case pipePong:
+		p.mu.Lock()
+		onPong := p.onPong
+		p.mu.Unlock()
+		if onPong != nil {
+			onPong()
+		}
+	}
+}
+
+func (p *pipeConn) Close() error {
+	return p.CloseWithReason(CloseNoStatusReceived, "")
+}
+
+func (p *pipeConn) CloseWithReason(code int, reason string) error {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.closeErr = &CloseError{Code: code, Reason: reason}
+		p.mu.Unlock()
+		close(p.closed)
+	})
+	return nil
+}
+
+// closeError 返回本端关闭时对端读取到的错误
+func (p *pipeConn) closeError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closeErr == nil {
+		return ErrPipeClosed
+	}
+	return p.closeErr
+}
+
+func (p *pipeConn) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	p.deadline = t
+	close(p.deadlineChanged)
+	p.deadlineChanged = make(chan struct{})
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *pipeConn) SetPongHandler(h func()) {
+	p.mu.Lock()
+	p.onPong = h
+	p.mu.Unlock()
+}
+
+func (p *pipeConn) RemoteAddr() string {
+	return "pipe"
+}
+
+// PipeTransport 通过内存管道直接连接到进程内的Engine，不经过网络
+type PipeTransport struct {
+	engine *Engine
+}
+
+// NewPipeTransport 创建连接到指定Engine的内存传输
+func NewPipeTransport(e *Engine) *PipeTransport {
+	return &PipeTransport{engine: e}
+}
+
+// Dial 创建一对内存管道，将服务端一端交给Engine处理
+func (t *PipeTransport) Dial(rawURL string, header http.Header) (FrameConn, error) {
+	client, server := NewPipe()
+	if _, err := t.engine.ServeConn(server); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
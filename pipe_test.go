@@ -0,0 +1,152 @@
+package Nexus
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipeReadWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []string
+	}{
+		{"single", []string{"hello"}},
+		{"multiple", []string{"a", "b", "c"}},
+		{"empty frame", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewPipe()
+			defer a.Close()
+			defer b.Close()
+			for _, f := range tt.frames {
+				if err := a.WriteFrame([]byte(f)); err != nil {
+					t.Fatalf("WriteFrame: %v", err)
+				}
+			}
+			for _, want := range tt.frames {
+				got, err := b.ReadFrame()
+				if err != nil {
+					t.Fatalf("ReadFrame: %v", err)
+				}
+				if string(got) != want {
+					t.Fatalf("ReadFrame = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPipeCloseDeliversPendingFramesThenReason(t *testing.T) {
+	a, b := NewPipe()
+	a.WriteFrame([]byte("last"))
+	a.CloseWithReason(ClosePolicyViolation, "bye")
+
+	got, err := b.ReadFrame()
+	if err != nil || string(got) != "last" {
+		t.Fatalf("ReadFrame = %q, %v, want pending frame", got, err)
+	}
+	_, err = b.ReadFrame()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != ClosePolicyViolation || ce.Reason != "bye" {
+		t.Fatalf("ReadFrame error = %v, want close 1008 bye", err)
+	}
+}
+
+func TestPipeReadDeadline(t *testing.T) {
+	a, b := NewPipe()
+	defer a.Close()
+	defer b.Close()
+
+	b.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	start := time.Now()
+	if _, err := b.ReadFrame(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ReadFrame error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("deadline took %v", elapsed)
+	}
+}
+
+func TestPipeReadDeadlineExtendedWhileBlocked(t *testing.T) {
+	a, b := NewPipe()
+	defer a.Close()
+	defer b.Close()
+
+	b.SetReadDeadline(time.Now().Add(30 * time.Millisecond))
+	result := make(chan error, 1)
+	go func() {
+		_, err := b.ReadFrame()
+		result <- err
+	}()
+
+	// 阻塞中的读取应按新的超时时间等待
+	time.Sleep(10 * time.Millisecond)
+	b.SetReadDeadline(time.Now().Add(time.Hour))
+	time.Sleep(60 * time.Millisecond)
+	a.WriteFrame([]byte("late"))
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("ReadFrame error = %v, want frame after extended deadline", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadFrame did not return")
+	}
+}
+
+func TestPipePingAnsweredByReadingPeer(t *testing.T) {
+	tests := []struct {
+		name       string
+		peerReads  bool
+		wantPonged bool
+	}{
+		{"peer reading", true, true},
+		{"peer not reading", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewPipe()
+			defer a.Close()
+			defer b.Close()
+
+			var ponged atomic.Bool
+			a.SetPongHandler(func() { ponged.Store(true) })
+			go func() {
+				for {
+					if _, err := a.ReadFrame(); err != nil {
+						return
+					}
+				}
+			}()
+			if tt.peerReads {
+				go func() {
+					for {
+						if _, err := b.ReadFrame(); err != nil {
+							return
+						}
+					}
+				}()
+			}
+
+			if err := a.Ping(); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			// Ping 不应同步触发本端的回调
+			if !tt.peerReads && ponged.Load() {
+				t.Fatal("pong handler fired without a reading peer")
+			}
+			deadline := time.Now().Add(200 * time.Millisecond)
+			for time.Now().Before(deadline) && !ponged.Load() {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if ponged.Load() != tt.wantPonged {
+				t.Fatalf("ponged = %v, want %v", ponged.Load(), tt.wantPonged)
+			}
+		})
+	}
+}
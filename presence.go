@@ -0,0 +1,82 @@
+package Nexus
+
+import (
+	"sort"
+	"strings"
+)
+
+// PresenceDiff 表示房间或主题在线身份的变化，作为推送消息的Body发送给房间成员或主题订阅者
+// 房间的变化只设置Room，主题的变化只设置Topic
+type PresenceDiff struct {
+	Room   string   `json:"room,omitempty"`
+	Topic  string   `json:"topic,omitempty"`
+	Joins  []string `json:"joins,omitempty"`
+	Leaves []string `json:"leaves,omitempty"`
+}
+
+// Presence 提供房间在线身份的查询
+// 同一身份的多个连接只计为一个在线成员，未绑定身份的连接以连接ID计
+// 查询结果只包含本节点的连接，集群中其他节点的在线身份需通过推送的 PresenceDiff 获知
+type Presence struct {
+	engine *Engine
+}
+
+// Presence 返回Engine的在线状态查询接口
+func (e *Engine) Presence() *Presence {
+	return &Presence{engine: e}
+}
+
+// List 返回房间内在线的身份列表
+func (p *Presence) List(room string) []string {
+	p.engine.roomsMu.Lock()
+	defer p.engine.roomsMu.Unlock()
+	rs, ok := p.engine.rooms[room]
+	if !ok {
+		return nil
+	}
+	identities := make([]string, 0, len(rs.identities))
+	for identity := range rs.identities {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	return identities
+}
+
+// ListTopic 返回订阅了主题的在线身份列表
+func (p *Presence) ListTopic(topic string) []string {
+	return p.List(topicRoom(topic))
+}
+
+// Online 判断身份是否在房间内在线
+func (p *Presence) Online(room, identity string) bool {
+	p.engine.roomsMu.Lock()
+	defer p.engine.roomsMu.Unlock()
+	rs, ok := p.engine.rooms[room]
+	if !ok {
+		return false
+	}
+	return rs.identities[identity] > 0
+}
+
+// publishPresence 向房间成员推送在线身份变化
+// 主题订阅对应的房间以主题标识，不暴露内部的房间名
+func (e *Engine) publishPresence(room string, joins, leaves []string) {
+	if !e.config.PresenceConfig.Enabled {
+		return
+	}
+	h := header{"path": e.config.PresenceConfig.Path}
+	diff := PresenceDiff{Joins: joins, Leaves: leaves}
+	if isTopicRoom(room) {
+		diff.Topic = strings.TrimPrefix(room, topicRoomPrefix)
+		h["topic"] = diff.Topic
+	} else {
+		diff.Room = room
+		h["room"] = room
+	}
+	msg := &ResMessage{
+		Status: StatusOK,
+		Header: h,
+		Body:   diff,
+	}
+	e.BroadcastToRoom(room, msg.Bytes())
+}
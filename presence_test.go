@@ -0,0 +1,128 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// newPresenceEngine 创建启用在线状态推送的Engine
+func newPresenceEngine() *Engine {
+	cfg := DefaultConfig()
+	cfg.PresenceConfig.Enabled = true
+	return NewWithConfig(cfg)
+}
+
+// readPresence 读取下一条在线状态变化
+func readPresence(t *testing.T, fc FrameConn) PresenceDiff {
+	t.Helper()
+	msg, _ := readUntil(t, fc, func(m ResMessage) bool { return m.Header["path"] == "/presence" })
+	data, _ := json.Marshal(msg.Body)
+	var diff PresenceDiff
+	json.Unmarshal(data, &diff)
+	return diff
+}
+
+func TestPresenceJoinLeave(t *testing.T) {
+	e := newPresenceEngine()
+	alice, aliceClient := newTestConn(t, e)
+	alice.SetIdentity("alice")
+	bob, bobClient := newTestConn(t, e)
+	bob.SetIdentity("bob")
+	alice2, alice2Client := newTestConn(t, e)
+	alice2.SetIdentity("alice")
+	anon, _ := newTestConn(t, e)
+
+	steps := []struct {
+		name     string
+		do       func()
+		wantList []string
+	}{
+		{"alice joins", func() { alice.Join("lobby") }, []string{"alice"}},
+		{"bob joins", func() { bob.Join("lobby") }, []string{"alice", "bob"}},
+		{"second alice connection joins", func() { alice2.Join("lobby") }, []string{"alice", "bob"}},
+		{"anonymous joins", func() { anon.Join("lobby") }, []string{anon.ID(), "alice", "bob"}},
+		{"anonymous leaves", func() { anon.Leave("lobby") }, []string{"alice", "bob"}},
+		{"second alice connection leaves", func() { alice2.Leave("lobby") }, []string{"alice", "bob"}},
+		{"alice disconnects", func() { aliceClient.Close() }, []string{"bob"}},
+	}
+	for _, step := range steps {
+		step.do()
+		if step.name == "alice disconnects" {
+			waitFor(t, func() bool { return len(e.RoomMembers("lobby")) == 1 })
+		}
+		if got := e.Presence().List("lobby"); !reflect.DeepEqual(got, step.wantList) {
+			t.Fatalf("%s: List = %v, want %v", step.name, got, step.wantList)
+		}
+	}
+
+	// 同一身份的其他连接进出房间不产生变化
+	want := []PresenceDiff{
+		{Room: "lobby", Joins: []string{"bob"}},
+		{Room: "lobby", Joins: []string{anon.ID()}},
+		{Room: "lobby", Leaves: []string{anon.ID()}},
+		{Room: "lobby", Leaves: []string{"alice"}},
+	}
+	for i, w := range want {
+		if got := readPresence(t, bobClient); !reflect.DeepEqual(got, w) {
+			t.Fatalf("diff %d = %+v, want %+v", i, got, w)
+		}
+	}
+	// 第二个alice连接加入时没有推送变化，它收到的第一条是匿名连接的加入
+	if got := readPresence(t, alice2Client); !reflect.DeepEqual(got, PresenceDiff{Room: "lobby", Joins: []string{anon.ID()}}) {
+		t.Fatalf("alice2 first diff = %+v, want anonymous join", got)
+	}
+	if !e.Presence().Online("lobby", "bob") || e.Presence().Online("lobby", "alice") {
+		t.Fatal("Online does not match List")
+	}
+	if members := e.RoomMembers("lobby"); len(members) != 1 || members[0] != bob {
+		t.Fatalf("RoomMembers = %v, want only bob", members)
+	}
+}
+
+func TestPresenceDisabled(t *testing.T) {
+	e := New()
+	conn, client := newTestConn(t, e)
+	conn.SetIdentity("alice")
+	conn.Join("lobby")
+	e.BroadcastToRoom("lobby", []byte("first"))
+	if data, err := readTestFrame(t, client); err != nil || string(data) != "first" {
+		t.Fatalf("ReadFrame = %q, %v, want room message without presence diff", data, err)
+	}
+	if got := e.Presence().List("lobby"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Fatalf("List = %v, want presence tracked even when diffs are disabled", got)
+	}
+}
+
+func TestPresenceTopicKeyedByTopic(t *testing.T) {
+	e := newPresenceEngine()
+	alice, aliceClient := newTestConn(t, e)
+	alice.SetIdentity("alice")
+	bob, _ := newTestConn(t, e)
+	bob.SetIdentity("bob")
+
+	alice.Join(topicRoom("/news"))
+	bob.Join(topicRoom("/news"))
+	bob.Leave(topicRoom("/news"))
+
+	want := []PresenceDiff{
+		{Topic: "/news", Joins: []string{"alice"}},
+		{Topic: "/news", Joins: []string{"bob"}},
+		{Topic: "/news", Leaves: []string{"bob"}},
+	}
+	for i, w := range want {
+		msg, _ := readUntil(t, aliceClient, func(m ResMessage) bool { return m.Header["path"] == "/presence" })
+		if _, ok := msg.Header["room"]; ok || msg.Header["topic"] != "/news" {
+			t.Fatalf("diff %d header = %v, want topic /news and no room", i, msg.Header)
+		}
+		data, _ := json.Marshal(msg.Body)
+		var got PresenceDiff
+		json.Unmarshal(data, &got)
+		if !reflect.DeepEqual(got, w) {
+			t.Fatalf("diff %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if got := e.Presence().ListTopic("/news"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Fatalf("ListTopic = %v, want [alice]", got)
+	}
+}
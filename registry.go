@@ -0,0 +1,135 @@
+package Nexus
+
+import "time"
+
+// Connections 返回当前所有连接的快照
+func (e *Engine) Connections() []*Connection {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	conns := make([]*Connection, 0, len(e.connections))
+	for conn := range e.connections {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Connection 根据ID查找连接
+func (e *Engine) Connection(id string) (*Connection, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	conn, ok := e.connIndex[id]
+	return conn, ok
+}
+
+// ConnectionsByIdentity 返回绑定了指定身份的所有连接
+func (e *Engine) ConnectionsByIdentity(identity string) []*Connection {
+	var conns []*Connection
+	for _, conn := range e.Connections() {
+		if conn.Identity() == identity {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// SendTo 向指定ID的连接发送消息
+// 连接不在本节点时，若配置了Broker则转发给其他节点，否则返回 ErrConnectionNotFound
+func (e *Engine) SendTo(id string, data []byte) error {
+	conn, ok := e.Connection(id)
+	if !ok {
+		if e.config.ClusterConfig.Broker == nil {
+			return ErrConnectionNotFound
+		}
+		return e.publish(channelConn, id, data)
+	}
+	return conn.Send(data)
+}
+
+// SendToIdentity 向绑定了指定身份的所有连接发送消息，包括其他节点上的连接
+// 身份不在线且配置了离线消息存储时，消息会被保存并在其下次上线时补发
+// 配置了Broker时，只有在 ClusterConfig.DeliveryTimeout 内没有任何节点确认投递才会保存
+func (e *Engine) SendToIdentity(identity string, data []byte) error {
+	delivered := e.sendToLocalIdentity(identity, data) > 0
+	if delivered || e.config.OfflineConfig.Store == nil || e.config.ClusterConfig.Broker == nil {
+		if !delivered {
+			e.storeOffline(identity, data)
+		}
+		return e.publish(channelIdentity, identity, data)
+	}
+
+	// 本节点无人接收，等待其他节点确认后再决定是否保存
+	id := GenerateUniqueString()
+	confirmed := e.awaitDelivery(id)
+	if err := e.publishEnvelope(channelIdentity, brokerEnvelope{Target: identity, Data: data, Reply: id}); err != nil {
+		e.cancelDelivery(id)
+		e.storeOffline(identity, data)
+		return err
+	}
+	go func() {
+		timer := time.NewTimer(e.config.ClusterConfig.DeliveryTimeout)
+		defer timer.Stop()
+		select {
+		case <-confirmed:
+		case <-timer.C:
+			e.cancelDelivery(id)
+			e.storeOffline(identity, data)
+		}
+	}()
+	return nil
+}
+
+// awaitDelivery 登记等待其他节点确认投递的消息，返回收到确认时关闭的通道
+func (e *Engine) awaitDelivery(id string) <-chan struct{} {
+	ch := make(chan struct{})
+	e.deliveriesMu.Lock()
+	e.deliveries[id] = ch
+	e.deliveriesMu.Unlock()
+	return ch
+}
+
+// confirmDelivery 处理其他节点的投递确认，多个节点确认同一消息时只处理第一次
+func (e *Engine) confirmDelivery(id string) {
+	e.deliveriesMu.Lock()
+	ch, ok := e.deliveries[id]
+	delete(e.deliveries, id)
+	e.deliveriesMu.Unlock()
+	if ok {
+		close(ch)
+	}
+}
+
+// cancelDelivery 不再等待消息的投递确认
+func (e *Engine) cancelDelivery(id string) {
+	e.deliveriesMu.Lock()
+	delete(e.deliveries, id)
+	e.deliveriesMu.Unlock()
+}
+
+// sendToLocalIdentity 向本节点内绑定了指定身份的连接发送消息
+// 返回成功放入发送通道或会话缓存的连接数
+func (e *Engine) sendToLocalIdentity(identity string, data []byte) int {
+	sent := 0
+	for _, conn := range e.ConnectionsByIdentity(identity) {
+		if err := conn.Send(data); err == nil {
+			sent++
+		}
+	}
+	sent += e.bufferDetached(data, func(c *Connection) bool {
+		return c.Identity() == identity
+	})
+	return sent
+}
+
+// Kick 强制断开指定ID的连接，reason会随关闭帧发送给客户端
+// 连接不在本节点且配置了Broker时，转发给其他节点处理，此时无法确认连接是否存在
+func (e *Engine) Kick(id string, reason string) error {
+	conn, ok := e.Connection(id)
+	if !ok {
+		if e.config.ClusterConfig.Broker == nil {
+			return ErrConnectionNotFound
+		}
+		return e.publish(channelKick, id, []byte(reason))
+	}
+	conn.Close(ClosePolicyViolation, reason)
+	return nil
+}
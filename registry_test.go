@@ -0,0 +1,76 @@
+package Nexus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectionLookupAfterUnregister(t *testing.T) {
+	e := New()
+	conn, client := newTestConn(t, e)
+	other, _ := newTestConn(t, e)
+	conn.SetIdentity("alice")
+
+	if got, ok := e.Connection(conn.ID()); !ok || got != conn {
+		t.Fatalf("Connection(%s) = %v, %v, want the connection", conn.ID(), got, ok)
+	}
+	if got := e.ConnectionsByIdentity("alice"); len(got) != 1 || got[0] != conn {
+		t.Fatalf("ConnectionsByIdentity = %v, want the connection", got)
+	}
+
+	client.Close()
+	waitFor(t, func() bool { return len(e.Connections()) == 1 })
+	if _, ok := e.Connection(conn.ID()); ok {
+		t.Fatal("Connection found after unregister")
+	}
+	if got := e.ConnectionsByIdentity("alice"); len(got) != 0 {
+		t.Fatalf("ConnectionsByIdentity after unregister = %v, want none", got)
+	}
+	if got := e.Connections(); got[0] != other {
+		t.Fatalf("Connections = %v, want only the remaining connection", got)
+	}
+	if err := e.SendTo(conn.ID(), []byte("hi")); !errors.Is(err, ErrConnectionNotFound) {
+		t.Fatalf("SendTo after unregister = %v, want ErrConnectionNotFound", err)
+	}
+}
+
+func TestKick(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      func(conn *Connection) string
+		wantErr error
+	}{
+		{"known connection", func(conn *Connection) string { return conn.ID() }, nil},
+		{"unknown id", func(*Connection) string { return "missing" }, ErrConnectionNotFound},
+		{"empty id", func(*Connection) string { return "" }, ErrConnectionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New()
+			conn, client := newTestConn(t, e)
+
+			if err := e.Kick(tt.id(conn), "bye"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Kick = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				// 踢出不存在的连接不影响已有连接
+				if _, ok := e.Connection(conn.ID()); !ok {
+					t.Fatal("existing connection unregistered by a failed Kick")
+				}
+				return
+			}
+
+			var ce *CloseError
+			if _, err := readTestFrame(t, client); !errors.As(err, &ce) || ce.Code != ClosePolicyViolation || ce.Reason != "bye" {
+				t.Fatalf("ReadFrame error = %v, want policy violation with reason", err)
+			}
+			waitFor(t, func() bool {
+				_, ok := e.Connection(conn.ID())
+				return !ok
+			})
+			if code, reason := conn.CloseStatus(); code != ClosePolicyViolation || reason != "bye" {
+				t.Fatalf("CloseStatus = %d, %q, want policy violation with reason", code, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReliablePath 可靠投递模式下推送消息和确认消息使用的路径
+// 推送消息的Body为原始消息，Header中的seq为序号，客户端以 ACK 方法请求该路径确认
+const ReliablePath = "/_nexus/push"
+
+// ErrDeliveryFailed 推送消息超过最大重试次数仍未被确认
+var ErrDeliveryFailed = errors.New("delivery failed: max retries exceeded")
+
+// outstanding 表示一条尚未被确认的推送消息
+type outstanding struct {
+	seq      uint64
+	id       string
+	raw      []byte
+	frame    []byte
+	attempts int
+	next     time.Time
+}
+
+// reliableState 记录连接上尚未确认的推送消息
+type reliableState struct {
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]*outstanding
+}
+
+// newReliableState 创建可靠投递状态
+func newReliableState() *reliableState {
+	return &reliableState{pending: make(map[uint64]*outstanding)}
+}
+
+// backoff 返回第attempts次发送后等待确认的时间
+func (cfg ReliableConfig) backoff(attempts int) time.Duration {
+	d := cfg.AckTimeout
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if cfg.MaxBackoff > 0 && d >= cfg.MaxBackoff {
+			return cfg.MaxBackoff
+		}
+	}
+	return d
+}
+
+// sendReliable 为消息分配序号并放入发送通道
+// 发送通道已满时消息保留在待确认列表中，由重试协程稍后发送
+func (c *Connection) sendReliable(data []byte) error {
+	if !json.Valid(data) {
+		// 非JSON消息无法封装，退化为普通发送
+		return c.enqueue(data)
+	}
+	return c.sendReliableID(GenerateUniqueString(), data)
+}
+
+// sendReliableID 使用指定的信封ID发送可靠投递消息
+// 恢复会话后重发的消息沿用原来的ID，客户端据此去重
+func (c *Connection) sendReliableID(id string, data []byte) error {
+	cfg := c.engine.config.ReliableConfig
+	r := c.reliable
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	msg := &ResMessage{
+		ID:     id,
+		Status: StatusOK,
+		Header: header{"path": ReliablePath, "seq": r.seq},
+		Body:   json.RawMessage(data),
+	}
+	o := &outstanding{
+		seq:   r.seq,
+		id:    id,
+		raw:   data,
+		frame: msg.Bytes(),
+	}
+	if c.enqueue(o.frame) == nil {
+		o.attempts = 1
+		o.next = time.Now().Add(cfg.backoff(1))
+	} else {
+		o.next = time.Now()
+	}
+	r.pending[o.seq] = o
+	return nil
+}
+
+// ack 确认指定序号的推送消息
+func (c *Connection) ack(seq uint64) {
+	if c.reliable == nil {
+		return
+	}
+	c.reliable.mu.Lock()
+	delete(c.reliable.pending, seq)
+	c.reliable.mu.Unlock()
+}
+
+// unacked 按序号返回所有尚未确认的消息，并清空待确认列表
+func (c *Connection) unacked() []*outstanding {
+	r := c.reliable
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seqs := make([]uint64, 0, len(r.pending))
+	for seq := range r.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	pending := make([]*outstanding, 0, len(seqs))
+	for _, seq := range seqs {
+		pending = append(pending, r.pending[seq])
+	}
+	r.pending = make(map[uint64]*outstanding)
+	return pending
+}
+
+// redeliverPump 定期重发超时未确认的推送消息
+func (c *Connection) redeliverPump() {
+	cfg := c.engine.config.ReliableConfig
+	interval := cfg.AckTimeout / 2
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.closeChan:
+			return
+		}
+
+		var failed []uint64
+		now := time.Now()
+		r := c.reliable
+		r.mu.Lock()
+		for seq, o := range r.pending {
+			if now.Before(o.next) {
+				continue
+			}
+			if o.attempts > cfg.MaxRetries {
+				failed = append(failed, seq)
+				delete(r.pending, seq)
+				continue
+			}
+			if c.enqueue(o.frame) == nil {
+				o.attempts++
+				o.next = now.Add(cfg.backoff(o.attempts))
+			}
+		}
+		r.mu.Unlock()
+
+		for _, seq := range failed {
+			c.engine.log(LogSession).Warn("message not acknowledged", "conn", c.id, "seq", seq)
+			c.engine.metrics.drop()
+			c.engine.fireError(c, ErrDeliveryFailed)
+		}
+	}
+}
+
+// handleAck 处理客户端的 ACK 系统消息
+func (e *Engine) handleAck(c *Context) {
+	body, _ := c.Request.Body.(map[string]any)
+	if seq, ok := body["seq"].(float64); ok {
+		c.connection.ack(uint64(seq))
+	}
+}
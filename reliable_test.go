@@ -0,0 +1,149 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// newReliableEngine 创建启用可靠投递的Engine
+func newReliableEngine(maxRetries int) *Engine {
+	cfg := DefaultConfig()
+	cfg.ReliableConfig.Enabled = true
+	cfg.ReliableConfig.AckTimeout = 20 * time.Millisecond
+	cfg.ReliableConfig.MaxBackoff = 20 * time.Millisecond
+	cfg.ReliableConfig.MaxRetries = maxRetries
+	return NewWithConfig(cfg)
+}
+
+// readEnvelope 读取一条可靠投递的推送消息
+func readEnvelope(t *testing.T, fc FrameConn) ResMessage {
+	t.Helper()
+	msg, _ := readUntil(t, fc, func(m ResMessage) bool { return m.Header["path"] == ReliablePath })
+	return msg
+}
+
+// ackFrame 返回确认指定推送消息的请求帧
+func ackFrame(msg ResMessage) []byte {
+	data, _ := json.Marshal(ReqMessage{ID: "ack", Method: ACK, Path: ReliablePath, Body: N{"seq": msg.Header["seq"]}})
+	return data
+}
+
+func TestReliableRedelivery(t *testing.T) {
+	tests := []struct {
+		name         string
+		redeliveries int
+		ack          bool
+		wantFailed   bool
+	}{
+		{"acked on first delivery", 0, true, false},
+		{"acked after redelivery", 1, true, false},
+		{"never acked", 2, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newReliableEngine(2)
+			failed := make(chan error, 1)
+			e.OnError(func(c *Connection, err error) {
+				select {
+				case failed <- err:
+				default:
+				}
+			})
+			conn, client := newTestConn(t, e)
+			conn.Send([]byte(`{"n":1}`))
+
+			first := readEnvelope(t, client)
+			for i := 0; i < tt.redeliveries; i++ {
+				again := readEnvelope(t, client)
+				if again.ID != first.ID || again.Header["seq"] != first.Header["seq"] {
+					t.Fatalf("redelivered %v/%v, want same envelope %v/%v", again.ID, again.Header["seq"], first.ID, first.Header["seq"])
+				}
+			}
+			if tt.ack {
+				client.WriteFrame(ackFrame(first))
+			}
+
+			select {
+			case err := <-failed:
+				if !tt.wantFailed || !errors.Is(err, ErrDeliveryFailed) {
+					t.Fatalf("error hook = %v, want failure: %v", err, tt.wantFailed)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantFailed {
+					t.Fatal("delivery failure not reported")
+				}
+			}
+			if !tt.wantFailed {
+				// 确认之后不再重发
+				for {
+					client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+					data, err := client.ReadFrame()
+					if errors.Is(err, os.ErrDeadlineExceeded) {
+						break
+					}
+					var msg ResMessage
+					if json.Unmarshal(data, &msg) == nil && msg.ID == first.ID {
+						t.Fatal("message redelivered after ack")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReliableResumeKeepsEnvelopeAndFrames(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ReliableConfig.Enabled = true
+	cfg.SessionConfig.Enabled = true
+	e := NewWithConfig(cfg)
+
+	// 旧连接不启动写协程，帧都留在发送通道中
+	_, server := NewPipe()
+	old := e.newConnection(server)
+	old.reliable = newReliableState()
+	e.startSession(old)
+	token := old.SessionID()
+	response := []byte(`{"id":"req-1","status":200}`)
+	old.enqueue(response)
+	old.Send([]byte(`{"n":1}`))
+
+	ids := make(map[string]bool)
+	for _, o := range old.reliable.pending {
+		ids[o.id] = true
+	}
+	e.detachSession(old)
+
+	_, client := newTestConn(t, e)
+	readEnvelope(t, client) // 新连接的会话令牌
+	res, frames := resume(t, client, token)
+	if res.Status != StatusOK {
+		t.Fatalf("resume status = %d, want 200", res.Status)
+	}
+
+	var gotResponse bool
+	resent := make(map[string]bool)
+	for _, data := range frames {
+		if string(data) == string(response) {
+			gotResponse = true
+			continue
+		}
+		var msg ResMessage
+		if json.Unmarshal(data, &msg) == nil && msg.Header["path"] == ReliablePath {
+			resent[msg.ID] = true
+		}
+	}
+	if !gotResponse {
+		t.Fatalf("frames = %q, want buffered response written as is", frames)
+	}
+	for id := range ids {
+		if !resent[id] {
+			t.Fatalf("resent envelopes %v, want original id %s", resent, id)
+		}
+	}
+	if len(resent) != len(ids) {
+		t.Fatalf("resent %d envelopes, want %d", len(resent), len(ids))
+	}
+}
@@ -0,0 +1,187 @@
+package Nexus
+
+import (
+	"sort"
+	"time"
+)
+
+// roomState 表示一个房间的成员信息
+type roomState struct {
+	// members 连接到其加入时所用在线身份的映射
+	members map[*Connection]string
+	// identities 在线身份到连接数的映射，用于多连接去重
+	identities map[string]int
+}
+
+// presenceKey 返回连接在房间中的在线身份，未绑定身份时使用连接ID
+func presenceKey(c *Connection) string {
+	if identity := c.Identity(); identity != "" {
+		return identity
+	}
+	return c.id
+}
+
+// Join 将连接加入房间
+func (e *Engine) Join(room string, c *Connection) {
+	e.roomsMu.Lock()
+	// 已关闭的连接不再加入，避免断开清理之后残留成员
+	if c.isClosed() {
+		e.roomsMu.Unlock()
+		return
+	}
+	rs, ok := e.rooms[room]
+	if !ok {
+		rs = &roomState{
+			members:    make(map[*Connection]string),
+			identities: make(map[string]int),
+		}
+		e.rooms[room] = rs
+	}
+	if _, joined := rs.members[c]; joined {
+		e.roomsMu.Unlock()
+		return
+	}
+	key := presenceKey(c)
+	rs.members[c] = key
+	rs.identities[key]++
+	first := rs.identities[key] == 1
+	e.roomsMu.Unlock()
+
+	if first {
+		e.publishPresence(room, []string{key}, nil)
+	}
+}
+
+// Leave 将连接移出房间
+func (e *Engine) Leave(room string, c *Connection) {
+	e.roomsMu.Lock()
+	key, last := e.leaveLocked(room, c)
+	e.roomsMu.Unlock()
+
+	if last {
+		e.publishPresence(room, nil, []string{key})
+	}
+}
+
+// leaveAll 将连接移出其加入的所有房间，在连接断开时调用
+// 配置了离线消息存储时，会记住身份所在的房间以便为其保存之后的房间消息
+func (e *Engine) leaveAll(c *Connection) {
+	type leave struct {
+		room    string
+		key     string
+		offline bool
+	}
+	var leaves []leave
+	identity := c.Identity()
+	now := time.Now()
+
+	e.roomsMu.Lock()
+	for room, rs := range e.rooms {
+		if _, ok := rs.members[c]; !ok {
+			continue
+		}
+		if key, last := e.leaveLocked(room, c); last {
+			offline := e.rememberOfflineRoomLocked(room, identity, now)
+			leaves = append(leaves, leave{room: room, key: key, offline: offline})
+		}
+	}
+	e.roomsMu.Unlock()
+
+	for _, l := range leaves {
+		if l.offline {
+			e.persistOfflineRoom(l.room, identity, now)
+		}
+		e.publishPresence(l.room, nil, []string{l.key})
+	}
+}
+
+// leaveLocked 移除房间成员，返回其在线身份以及该身份是否已全部离开
+// 调用方需持有 roomsMu
+func (e *Engine) leaveLocked(room string, c *Connection) (string, bool) {
+	rs, ok := e.rooms[room]
+	if !ok {
+		return "", false
+	}
+	key, ok := rs.members[c]
+	if !ok {
+		return "", false
+	}
+	delete(rs.members, c)
+	rs.identities[key]--
+	last := rs.identities[key] == 0
+	if last {
+		delete(rs.identities, key)
+	}
+	if len(rs.members) == 0 {
+		delete(e.rooms, room)
+	}
+	return key, last
+}
+
+// Rooms 返回当前所有非空房间的名称
+func (e *Engine) Rooms() []string {
+	e.roomsMu.Lock()
+	defer e.roomsMu.Unlock()
+	rooms := make([]string, 0, len(e.rooms))
+	for room := range e.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// RoomMembers 返回房间内的所有连接
+func (e *Engine) RoomMembers(room string) []*Connection {
+	e.roomsMu.Lock()
+	defer e.roomsMu.Unlock()
+	rs, ok := e.rooms[room]
+	if !ok {
+		return nil
+	}
+	conns := make([]*Connection, 0, len(rs.members))
+	for conn := range rs.members {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// BroadcastToRoom 向房间内的所有连接发送消息，发送通道已满的连接会丢弃该消息
+// 配置了Broker时，其他节点上该房间的成员也会收到消息
+func (e *Engine) BroadcastToRoom(room string, data []byte) {
+	e.broadcastToLocalRoom(room, data)
+	e.publish(channelRoom, room, data)
+}
+
+// broadcastToLocalRoom 向本节点内房间的所有连接发送消息，并为房间内的离线身份保存消息
+func (e *Engine) broadcastToLocalRoom(room string, data []byte) {
+	for _, conn := range e.RoomMembers(room) {
+		if err := conn.Send(data); err != nil {
+			e.log(LogConnection).Warn("room send failed", "room", room, "conn", conn.id, "error", err)
+		}
+	}
+	e.storeForOfflineMembers(room, data)
+}
+
+// Join 将连接加入房间
+func (c *Connection) Join(room string) {
+	c.engine.Join(room, c)
+}
+
+// Leave 将连接移出房间
+func (c *Connection) Leave(room string) {
+	c.engine.Leave(room, c)
+}
+
+// Rooms 返回连接已加入的房间
+func (c *Connection) Rooms() []string {
+	c.engine.roomsMu.Lock()
+	defer c.engine.roomsMu.Unlock()
+	var rooms []string
+	for room, rs := range c.engine.rooms {
+		if _, ok := rs.members[c]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.Strings(rooms)
+	return rooms
+}
@@ -1,5 +1,14 @@
 package Nexus
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
 type IRouter interface {
 	IRoutes
 	Group(string, ...HandlerFunc) *RouterGroup
@@ -9,10 +18,24 @@ type IRoutes interface {
 	Use(...HandlerFunc) IRoutes
 
 	Handle(string, string, ...HandlerFunc) IRoutes
-	GET(string, ...HandlerFunc) IRoutes
-	POST(string, ...HandlerFunc) IRoutes
-	DELETE(string, ...HandlerFunc) IRoutes
-	PUT(string, ...HandlerFunc) IRoutes
+	GET(string, ...HandlerFunc) IRoute
+	POST(string, ...HandlerFunc) IRoute
+	DELETE(string, ...HandlerFunc) IRoute
+	PUT(string, ...HandlerFunc) IRoute
+	SUBSCRIBE(string, ...HandlerFunc) IRoute
+	UNSUBSCRIBE(string, ...HandlerFunc) IRoute
+}
+
+// IRoute 注册路由后返回的句柄，Name 和 Meta 作用于刚注册的路由，其他方法仍在所属的路由组上注册
+//
+//	e.GET("/users/:id", getUser).Name("user")
+//	e.POST("/orders", createOrder).Meta(Nexus.RouteMeta{Permission: "order:write", Timeout: 5 * time.Second})
+type IRoute interface {
+	IRoutes
+	// Name 为路由命名，之后可以通过 Engine.URL 按名称生成路径
+	Name(string) IRoute
+	// Meta 为路由附加元数据，中间件可以通过 Context.RouteMeta 读取
+	Meta(RouteMeta) IRoute
 }
 
 type RouterGroup struct {
@@ -23,24 +46,34 @@ type RouterGroup struct {
 }
 
 func (r *RouterGroup) ParsePath(method, path string) (handlers HandlerFuncList, params Params, ok bool) {
+	handlers, params, _, ok = r.lookup(method, path)
+	return handlers, params, ok
+}
+
+// lookup 查找路由，同时返回注册路由时使用的完整路径，如 /users/:id
+func (r *RouterGroup) lookup(method, path string) (handlers HandlerFuncList, params Params, fullPath string, ok bool) {
+
+	r.engine.routesMu.RLock()
+	defer r.engine.routesMu.RUnlock()
 
 	// 获取对应方法的路由树根节点
 	root := r.engine.trees.get(method)
 	if root == nil {
-		return nil, nil, false
+		return nil, nil, "", false
 	}
 	var pv nodeValue
-	var skipped []skippedNode
+	// 回溯节点最多每个路径段一个，需要预先分配容量
+	skipped := make([]skippedNode, 0, strings.Count(path, "/")+1)
 	pv = root.getValue(path, &params, &skipped, true)
 
 	if pv.handlers != nil {
 		if pv.params != nil {
-			return pv.handlers, *pv.params, true
+			return pv.handlers, *pv.params, pv.fullPath, true
 		}
-		return pv.handlers, nil, true
+		return pv.handlers, nil, pv.fullPath, true
 	}
 
-	return nil, nil, false
+	return nil, nil, "", false
 }
 
 var _ IRouter = (*RouterGroup)(nil)
@@ -56,32 +89,62 @@ func (r *RouterGroup) Group(relativePath string, handlers ...HandlerFunc) *Route
 		engine:   r.engine,
 	}
 }
-func (r *RouterGroup) handle(httpMethod, relativePath string, handlers HandlerFuncList) IRoutes {
+func (r *RouterGroup) handle(httpMethod, relativePath string, handlers HandlerFuncList) IRoute {
 	absolutePath := r.calculateAbsolutePath(relativePath)
 	handlers = r.combineHandlers(handlers)
 	r.engine.addRoute(httpMethod, absolutePath, handlers)
-	return r.returnObj()
+	return &route{IRoutes: r.returnObj(), engine: r.engine, key: routeKey{method: httpMethod, route: absolutePath}}
+}
+
+// route IRoute 的实现，key 为注册时的方法和完整路径
+type route struct {
+	IRoutes
+	engine *Engine
+	key    routeKey
+}
+
+var _ IRoute = (*route)(nil)
+
+func (rt *route) Name(name string) IRoute {
+	rt.engine.nameRoute(rt.key, name)
+	return rt
+}
+
+func (rt *route) Meta(meta RouteMeta) IRoute {
+	rt.engine.setRouteMeta(rt.key, meta)
+	return rt
 }
 
 func (r *RouterGroup) Handle(string, string, ...HandlerFunc) IRoutes {
 	return r.returnObj()
 }
 
-func (r *RouterGroup) GET(path string, handler ...HandlerFunc) IRoutes {
+func (r *RouterGroup) GET(path string, handler ...HandlerFunc) IRoute {
 	return r.handle(GET, path, handler)
 }
 
-func (r *RouterGroup) POST(path string, handler ...HandlerFunc) IRoutes {
+func (r *RouterGroup) POST(path string, handler ...HandlerFunc) IRoute {
 	return r.handle(POST, path, handler)
 }
 
-func (r *RouterGroup) PUT(path string, handler ...HandlerFunc) IRoutes {
+func (r *RouterGroup) PUT(path string, handler ...HandlerFunc) IRoute {
 	return r.handle(PUT, path, handler)
 }
-func (r *RouterGroup) DELETE(path string, handler ...HandlerFunc) IRoutes {
+func (r *RouterGroup) DELETE(path string, handler ...HandlerFunc) IRoute {
 	return r.handle(DELETE, path, handler)
 }
 
+// SUBSCRIBE 注册主题的订阅路由，处理函数返回非2xx状态时拒绝订阅
+// 客户端以 /rooms/:id 这样的模式订阅时，路由参数的值是模式中的路径段本身，如 c.Param("id") 为 ":id"
+func (r *RouterGroup) SUBSCRIBE(path string, handler ...HandlerFunc) IRoute {
+	return r.handle(SUBSCRIBE, path, handler)
+}
+
+// UNSUBSCRIBE 注册主题的取消订阅路由，未注册时取消订阅总是成功
+func (r *RouterGroup) UNSUBSCRIBE(path string, handler ...HandlerFunc) IRoute {
+	return r.handle(UNSUBSCRIBE, path, handler)
+}
+
 func (r *RouterGroup) returnObj() IRoutes {
 	if r.root {
 		return r.engine
@@ -113,3 +176,183 @@ func (r *RouterGroup) combineHandlers(handlers HandlerFuncList) HandlerFuncList
 	// 返回合并后的处理函数列表。
 	return mergedHandlers
 }
+
+// RouteInfo 表示一条已注册的路由
+type RouteInfo struct {
+	// 请求方法
+	Method string `json:"method"`
+	// 路由名称，未命名时为空
+	Name string `json:"name,omitempty"`
+	// 注册时的完整路径，如 /users/:id
+	Path string `json:"path"`
+	// 最后一个处理函数的名称
+	Handler string `json:"handler"`
+	// 包括中间件在内的整个处理链的函数名称
+	Handlers []string `json:"handlers"`
+	// 路由元数据，未设置时为nil
+	Meta *RouteMeta `json:"meta,omitempty"`
+}
+
+// Routes 返回按方法和路径排序的所有已注册路由
+func (e *Engine) Routes() []RouteInfo {
+	var routes []RouteInfo
+	e.routesMu.RLock()
+	for _, tree := range e.trees {
+		tree.root.walk(func(n *node) {
+			names := make([]string, len(n.handlers))
+			for i, h := range n.handlers {
+				names[i] = nameOfFunction(h)
+			}
+			routes = append(routes, RouteInfo{
+				Method:   tree.method,
+				Name:     e.routeNames[routeKey{method: tree.method, route: n.fullPath}],
+				Path:     n.fullPath,
+				Handler:  names[len(names)-1],
+				Handlers: names,
+				Meta:     e.routeMeta[routeKey{method: tree.method, route: n.fullPath}],
+			})
+		})
+	}
+	e.routesMu.RUnlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// walk 按深度优先遍历带有处理函数的节点
+func (n *node) walk(fn func(*node)) {
+	if n.handlers != nil {
+		fn(n)
+	}
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+}
+
+// debugPrintRoutes 调试模式下输出路由表
+func (e *Engine) debugPrintRoutes() {
+	if !e.config.LogConfig.Debug {
+		return
+	}
+	for _, route := range e.Routes() {
+		e.log(LogRouter).Debug("route",
+			"method", route.Method,
+			"path", route.Path,
+			"handler", route.Handler,
+			"handlers", len(route.Handlers),
+		)
+	}
+}
+
+// ErrRouteNotFound 指定名称的路由不存在
+var ErrRouteNotFound = errors.New("route not found")
+
+// nameRoute 为路由命名，名称重复时panic
+func (e *Engine) nameRoute(rk routeKey, name string) {
+	assert1(name != "", "route name can not be empty")
+	e.routesMu.Lock()
+	defer e.routesMu.Unlock()
+	if existing, ok := e.namedRoutes[name]; ok && existing != rk {
+		panic(fmt.Sprintf("route name %q is already used by %s %s", name, existing.method, existing.route))
+	}
+	e.namedRoutes[name] = rk
+	e.routeNames[rk] = name
+}
+
+// URL 按路由名称生成具体路径，params 为交替出现的参数名和参数值
+//
+//	e.URL("user", "id", "42") // /users/42
+//
+// 参数值会按路径段转义，路由不存在、缺少参数或参数未在路由中声明时返回错误
+func (e *Engine) URL(name string, params ...string) (string, error) {
+	e.routesMu.RLock()
+	rk, ok := e.namedRoutes[name]
+	e.routesMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrRouteNotFound, name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("route %q: params must be key/value pairs", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	// 与路由树的解析方式一致，参数可以出现在路径段的任意位置，如 /user_:name
+	var b strings.Builder
+	path := rk.route
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			b.WriteString(path)
+			break
+		}
+		b.WriteString(path[:i])
+		path = path[i+len(wildcard):]
+
+		key := wildcard[1:]
+		value, ok := values[key]
+		if !ok || value == "" {
+			return "", fmt.Errorf("route %q: missing parameter %q", name, key)
+		}
+		if wildcard[0] == ':' && strings.Contains(value, "/") {
+			return "", fmt.Errorf("route %q: parameter %q must not contain '/'", name, key)
+		}
+		if wildcard[0] == '*' {
+			// 通配参数可以跨越多个路径段，逐段转义并保留分隔符
+			parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			value = strings.Join(parts, "/")
+		} else {
+			value = url.PathEscape(value)
+		}
+		b.WriteString(value)
+		delete(values, key)
+	}
+	for key := range values {
+		return "", fmt.Errorf("route %q: unknown parameter %q", name, key)
+	}
+	return b.String(), nil
+}
+
+// RouteMeta 路由元数据，框架只负责保存，由中间件按需解释
+type RouteMeta struct {
+	// 路由说明
+	Description string `json:"description,omitempty"`
+	// 访问路由需要的权限
+	Permission string `json:"permission,omitempty"`
+	// 每个连接每秒允许的请求数，0表示不限制
+	RateLimit int `json:"rate_limit,omitempty"`
+	// 处理超时时间，0表示不限制
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// 是否为流式路由，处理函数会持续推送消息
+	Streaming bool `json:"streaming,omitempty"`
+	// 自定义元数据
+	Extra map[string]any `json:"extra,omitempty"`
+	// 请求体的示例值，如 CreateOrderRequest{}，只用于在API文档中生成请求体结构，不会校验或解码请求
+	Request any `json:"-"`
+	// 响应体的示例值，只用于在API文档中生成响应体结构
+	// 只有 SUBSCRIBE 路由的值会作为推送消息体列出，通过 Publish、BroadcastToRoom 等方式推送的其他消息不在文档中
+	Response any `json:"-"`
+}
+
+// setRouteMeta 为路由设置元数据，重复设置时覆盖
+func (e *Engine) setRouteMeta(rk routeKey, meta RouteMeta) {
+	e.routesMu.Lock()
+	defer e.routesMu.Unlock()
+	e.routeMeta[rk] = &meta
+}
+
+// routeMetaOf 返回路由的元数据，未设置时返回nil
+func (e *Engine) routeMetaOf(rk routeKey) *RouteMeta {
+	e.routesMu.RLock()
+	defer e.routesMu.RUnlock()
+	return e.routeMeta[rk]
+}
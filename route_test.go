@@ -0,0 +1,112 @@
+package Nexus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRoutesConcurrentWithRegistration(t *testing.T) {
+	e := New()
+	e.GET("/users/:id", func(c *Context) {})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			e.GET(fmt.Sprintf("/items/%d", i), func(c *Context) {})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			e.Routes()
+			if _, _, ok := e.ParsePath(GET, "/users/7"); !ok {
+				t.Error("ParsePath(/users/7) not found while registering routes")
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if got := len(e.Routes()); got != 101 {
+		t.Fatalf("len(Routes()) = %d, want 101", got)
+	}
+}
+
+func TestEngineURL(t *testing.T) {
+	e := New()
+	h := func(c *Context) {}
+	e.GET("/users/:id", h).Name("user")
+	e.GET("/users/:id/posts/:post", h).Name("post")
+	e.GET("/files/*path", h).Name("file")
+	e.GET("/about", h).Name("about")
+	e.GET("/user_:name/v:version", h).Name("versioned")
+
+	tests := []struct {
+		name    string
+		params  []string
+		want    string
+		wantErr bool
+	}{
+		{"user", []string{"id", "42"}, "/users/42", false},
+		{"post", []string{"id", "1", "post", "2"}, "/users/1/posts/2", false},
+		{"about", nil, "/about", false},
+		{"versioned", []string{"name", "bob", "version", "2"}, "/user_bob/v2", false},
+		{"versioned", []string{"name", "a b", "version", "2"}, "/user_a%20b/v2", false},
+		{"versioned", []string{"name", "bob"}, "", true},
+		{"user", []string{"id", "a b?c"}, "/users/a%20b%3Fc", false},
+		{"file", []string{"path", "/docs/a b.txt"}, "/files/docs/a%20b.txt", false},
+		{"user", []string{"id", "a/b"}, "", true},
+		{"user", nil, "", true},
+		{"user", []string{"id"}, "", true},
+		{"user", []string{"id", "1", "extra", "2"}, "", true},
+		{"missing", nil, "", true},
+	}
+	for _, tt := range tests {
+		got, err := e.URL(tt.name, tt.params...)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("URL(%q, %q) = %q, %v, want %q, error %v", tt.name, tt.params, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := e.URL("missing"); !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("URL(missing) error = %v, want ErrRouteNotFound", err)
+	}
+}
+
+func TestRouteNameAndMetaApplyToRegisteredRoute(t *testing.T) {
+	e := New()
+	h := func(c *Context) {}
+	api := e.Group("/api")
+	admin := e.Group("/admin")
+
+	api.GET("/users", h)
+	admin.GET("/stats", h).Name("stats").Meta(RouteMeta{Permission: "admin"})
+	api.POST("/users", h).Meta(RouteMeta{Permission: "user:write"}).Name("create-user")
+
+	tests := []struct {
+		method, path string
+		wantName     string
+		wantPerm     string
+	}{
+		{GET, "/api/users", "", ""},
+		{GET, "/admin/stats", "stats", "admin"},
+		{POST, "/api/users", "create-user", "user:write"},
+	}
+	routes := make(map[routeKey]RouteInfo)
+	for _, info := range e.Routes() {
+		routes[routeKey{method: info.Method, route: info.Path}] = info
+	}
+	for _, tt := range tests {
+		info := routes[routeKey{method: tt.method, route: tt.path}]
+		var perm string
+		if info.Meta != nil {
+			perm = info.Meta.Permission
+		}
+		if info.Name != tt.wantName || perm != tt.wantPerm {
+			t.Errorf("%s %s: name %q, permission %q, want %q, %q", tt.method, tt.path, info.Name, perm, tt.wantName, tt.wantPerm)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package Nexus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// asyncAPIVersion 生成的文档遵循的AsyncAPI版本
+const asyncAPIVersion = "2.6.0"
+
+// SchemaJSON 返回以JSON格式描述所有路由的AsyncAPI风格文档
+// 每个路径对应一个频道：普通路由是客户端发出的请求（publish），SUBSCRIBE 路由是服务端推送的主题（subscribe）
+// 消息体结构由 RouteMeta.Request 和 RouteMeta.Response 的Go类型生成
+func (e *Engine) SchemaJSON() ([]byte, error) {
+	return json.MarshalIndent(e.schemaDocument(), "", "  ")
+}
+
+// SchemaYAML 返回以YAML格式描述所有路由的AsyncAPI风格文档
+func (e *Engine) SchemaYAML() ([]byte, error) {
+	// 先经过JSON转换为通用结构，使YAML与JSON文档的字段名保持一致
+	data, err := json.Marshal(e.schemaDocument())
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writeYAML(&buf, doc, 0)
+	return buf.Bytes(), nil
+}
+
+// schemaDocument 生成文档，不包括文档路由本身
+func (e *Engine) schemaDocument() N {
+	cfg := e.config.SchemaConfig
+	reserved := strings.TrimSuffix(cfg.Path, "/")
+
+	channels := N{}
+	for _, route := range e.Routes() {
+		if route.Path == reserved || strings.HasPrefix(route.Path, reserved+"/") {
+			continue
+		}
+		name, params := channelName(route.Path)
+		channel, ok := channels[name].(N)
+		if !ok {
+			channel = N{}
+			if len(params) > 0 {
+				parameters := N{}
+				for _, p := range params {
+					parameters[p] = N{"schema": N{"type": "string"}}
+				}
+				channel["parameters"] = parameters
+			}
+			channels[name] = channel
+		}
+
+		meta := RouteMeta{}
+		if route.Meta != nil {
+			meta = *route.Meta
+		}
+		if route.Method == SUBSCRIBE {
+			channel["subscribe"] = subscribeOperation(route, meta)
+			continue
+		}
+		message := requestMessage(route, meta)
+		op, ok := channel["publish"].(N)
+		if !ok {
+			channel["publish"] = N{"message": message}
+			continue
+		}
+		// 同一路径有多个方法时以 oneOf 列出
+		existing := op["message"].(N)
+		if list, ok := existing["oneOf"].([]N); ok {
+			existing["oneOf"] = append(list, message)
+		} else {
+			op["message"] = N{"oneOf": []N{existing, message}}
+		}
+	}
+
+	return N{
+		"asyncapi": asyncAPIVersion,
+		"info": N{
+			"title":       cfg.Title,
+			"version":     cfg.Version,
+			"description": cfg.Description,
+		},
+		"defaultContentType": "application/json",
+		"channels":           channels,
+	}
+}
+
+// channelName 把 /users/:id 形式的路由路径转换为 /users/{id}，并返回参数名
+func channelName(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// requestMessage 描述一个请求消息
+func requestMessage(route RouteInfo, meta RouteMeta) N {
+	message := N{
+		"name":          route.Method,
+		"x-nexus-route": route.Path,
+	}
+	if route.Name != "" {
+		message["messageId"] = route.Name
+	}
+	if meta.Description != "" {
+		message["summary"] = meta.Description
+	}
+	if meta.Request != nil {
+		message["payload"] = typeSchema(reflect.TypeOf(meta.Request), nil)
+	}
+	if meta.Response != nil {
+		message["x-response"] = typeSchema(reflect.TypeOf(meta.Response), nil)
+	}
+	addMetaExtensions(message, meta)
+	return message
+}
+
+// subscribeOperation 描述一个推送主题，消息体结构来自 RouteMeta.Response
+func subscribeOperation(route RouteInfo, meta RouteMeta) N {
+	message := N{
+		"name":          "push",
+		"x-nexus-route": route.Path,
+	}
+	if meta.Response != nil {
+		message["payload"] = typeSchema(reflect.TypeOf(meta.Response), nil)
+	}
+	op := N{"message": message}
+	if route.Name != "" {
+		op["operationId"] = route.Name
+	}
+	if meta.Description != "" {
+		op["summary"] = meta.Description
+	}
+	addMetaExtensions(op, meta)
+	return op
+}
+
+// addMetaExtensions 以 x-nexus- 扩展字段输出路由元数据
+func addMetaExtensions(target N, meta RouteMeta) {
+	if meta.Permission != "" {
+		target["x-nexus-permission"] = meta.Permission
+	}
+	if meta.RateLimit > 0 {
+		target["x-nexus-rate-limit"] = meta.RateLimit
+	}
+	if meta.Timeout > 0 {
+		target["x-nexus-timeout"] = meta.Timeout.String()
+	}
+	if meta.Streaming {
+		target["x-nexus-streaming"] = true
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// typeSchema 按encoding/json的序列化规则生成Go类型的JSON Schema
+// visiting 记录正在展开的结构体，遇到循环引用时只输出类型
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) N {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return N{"type": "string", "format": "date-time"}
+	case durationType:
+		return N{"type": "integer", "description": "nanoseconds"}
+	case rawMessageType:
+		return N{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return N{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return N{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return N{"type": "number"}
+	case reflect.String:
+		return N{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return N{"type": "string", "contentEncoding": "base64"}
+		}
+		return N{"type": "array", "items": typeSchema(t.Elem(), visiting)}
+	case reflect.Map:
+		return N{"type": "object", "additionalProperties": typeSchema(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return N{"type": "object", "x-go-type": t.String()}
+		}
+		if visiting == nil {
+			visiting = make(map[reflect.Type]bool)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := N{}
+		var required []string
+		addStructFields(t, properties, &required, visiting)
+		schema := N{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	}
+	// interface等无法确定结构的类型
+	return N{}
+}
+
+// addStructFields 收集结构体的JSON字段，匿名嵌入的结构体字段会被展开
+func addStructFields(t reflect.Type, properties N, required *[]string, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			addStructFields(ft, properties, required, visiting)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := typeSchema(f.Type, visiting)
+		if strings.Contains(opts, "string") {
+			schema = N{"type": "string"}
+		}
+		properties[name] = schema
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// writeYAML 把JSON解码得到的通用结构写为YAML，映射的键按字母顺序输出
+func writeYAML(buf *bytes.Buffer, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			buf.WriteString(pad + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteString(pad + yamlString(k) + ":")
+			writeYAMLValue(buf, v[k], indent)
+		}
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range v {
+			buf.WriteString(pad + "-")
+			writeYAMLValue(buf, item, indent)
+		}
+	default:
+		buf.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLValue 写出键或列表项之后的值，非空的映射和列表换行缩进
+func writeYAMLValue(buf *bytes.Buffer, v any, indent int) {
+	switch c := v.(type) {
+	case map[string]any:
+		if len(c) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, c, indent+1)
+			return
+		}
+		buf.WriteString(" {}\n")
+	case []any:
+		if len(c) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, c, indent+1)
+			return
+		}
+		buf.WriteString(" []\n")
+	default:
+		buf.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+// yamlPlain 不需要加引号的字符串
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./{}-]*$`)
+
+// yamlString 输出字符串，可能被解析为其他类型或包含特殊字符时加引号
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		return strconv.Quote(s)
+	}
+	if yamlPlain.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// yamlScalar 输出标量
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return yamlString(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// SchemaHandler 返回输出API文档的http.Handler，请求参数 format=yaml 时输出YAML，否则输出JSON
+func (e *Engine) SchemaHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data []byte
+		var err error
+		if r.URL.Query().Get("format") == "yaml" {
+			w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+			data, err = e.SchemaYAML()
+		} else {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			data, err = e.SchemaJSON()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	})
+}
+
+// registerSchemaRoutes 注册输出API文档的保留路由，响应体即为JSON文档
+// YAML文档无法作为响应体原样返回，只通过 SchemaHandler 以 format=yaml 提供
+//
+//	GET <path>        JSON文档
+func (e *Engine) registerSchemaRoutes() {
+	e.GET(e.config.SchemaConfig.Path, func(c *Context) {
+		c.Response = &ResMessage{ID: c.Request.ID, Header: c.Header, Status: StatusOK, Body: e.schemaDocument()}
+		c.Exit()
+	})
+}
@@ -0,0 +1,59 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSchemaRoutesWithLiteralConfig(t *testing.T) {
+	e := NewWithConfig(Config{SchemaConfig: SchemaConfig{Enabled: true}})
+	e.GET("/users/:id", func(c *Context) {})
+
+	tests := []struct {
+		path   string
+		wantOK bool
+	}{
+		{"/_schema", true},
+		{"/_schema/yaml", false},
+	}
+	for _, tt := range tests {
+		if _, _, ok := e.ParsePath(GET, tt.path); ok != tt.wantOK {
+			t.Errorf("route %s registered = %v, want %v", tt.path, ok, tt.wantOK)
+		}
+	}
+	channels, _ := e.schemaDocument()["channels"].(N)
+	if _, ok := channels["/users/{id}"]; !ok || len(channels) != 1 {
+		t.Fatalf("channels = %v, want only /users/{id}", channels)
+	}
+}
+
+func TestSchemaHandlerFormats(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SchemaConfig.Enabled = true
+	e := NewWithConfig(cfg)
+	e.GET("/users", func(c *Context) {})
+
+	tests := []struct {
+		query       string
+		contentType string
+		valid       func(body string) bool
+	}{
+		{"", "application/json; charset=utf-8", func(body string) bool { return json.Valid([]byte(body)) }},
+		{"?format=yaml", "application/yaml; charset=utf-8", func(body string) bool {
+			return strings.HasPrefix(body, "asyncapi: ") && strings.Contains(body, "\nchannels:\n")
+		}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.SchemaHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_schema"+tt.query, nil))
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%q: Content-Type = %q, want %q", tt.query, got, tt.contentType)
+		}
+		if !tt.valid(rec.Body.String()) {
+			t.Errorf("%q: unexpected body\n%s", tt.query, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package Nexus
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionPath 会话相关系统消息使用的路径
+// 服务端在连接建立后向该路径推送会话令牌，客户端重连后以 RESUME 方法请求该路径恢复会话
+const SessionPath = "/_nexus/session"
+
+// session 表示跨越多次连接的客户端会话
+// 连接断开后会话在保留期内保持房间成员身份，并缓存期间发给它的消息
+type session struct {
+	token      string
+	mu         sync.Mutex
+	conn       *Connection
+	detached   bool
+	detachedAt time.Time
+	// expired 会话已过期，不再缓存消息
+	expired bool
+	buffer  []bufferedFrame
+}
+
+// bufferedFrame 表示会话断开期间缓存的一条消息
+type bufferedFrame struct {
+	data []byte
+	// encoded 为true时data是已编码好的帧，例如响应和会话令牌，恢复后原样写出
+	encoded bool
+	// id 可靠投递中尚未确认的消息的信封ID，恢复后沿用该ID重发
+	id string
+}
+
+// replay 把缓存的消息发送到恢复会话的连接
+func (c *Connection) replay(f bufferedFrame) error {
+	switch {
+	case f.encoded:
+		return c.enqueue(f.data)
+	case f.id != "" && c.reliable != nil:
+		return c.sendReliableID(f.id, f.data)
+	default:
+		return c.Send(f.data)
+	}
+}
+
+// push 在会话断开期间缓存发给c的消息，超出上限时丢弃最早的消息
+func (s *session) push(c *Connection, data []byte, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.detached || s.expired || s.conn != c {
+		return false
+	}
+	if limit > 0 && len(s.buffer) >= limit {
+		s.buffer = s.buffer[1:]
+	}
+	s.buffer = append(s.buffer, bufferedFrame{data: data})
+	return true
+}
+
+// SessionID 返回连接所属会话的令牌，未启用会话时返回空字符串
+func (c *Connection) SessionID() string {
+	s := c.getSession()
+	if s == nil {
+		return ""
+	}
+	return s.token
+}
+
+// getSession 返回连接当前所属的会话
+func (c *Connection) getSession() *session {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	return c.session
+}
+
+// setSession 设置连接所属的会话
+func (c *Connection) setSession(s *session) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+	c.session = s
+}
+
+// startSession 为新连接创建会话并推送会话令牌
+func (e *Engine) startSession(c *Connection) {
+	s := &session{
+		token: GenerateUniqueString(),
+		conn:  c,
+	}
+	c.setSession(s)
+
+	e.sessionsMu.Lock()
+	e.sessions[s.token] = s
+	e.sessionsMu.Unlock()
+
+	msg := &ResMessage{
+		Status: StatusOK,
+		Header: header{"path": SessionPath},
+		Body:   N{"session": s.token},
+	}
+	c.Send(msg.Bytes())
+}
+
+// detachSession 在连接断开时保留会话，收集发送通道中尚未写出的消息
+func (e *Engine) detachSession(c *Connection) {
+	s := c.getSession()
+	s.mu.Lock()
+	s.detached = true
+	s.detachedAt = time.Now()
+	var frames [][]byte
+	for drained := false; !drained; {
+		select {
+		case data := <-c.send:
+			frames = append(frames, data)
+		default:
+			drained = true
+		}
+	}
+	// 可靠投递模式下未确认的推送帧由待确认列表统一补发，其余帧原样缓存
+	var pending []*outstanding
+	envelopes := make(map[string]struct{})
+	if c.reliable != nil {
+		pending = c.unacked()
+		for _, o := range pending {
+			envelopes[string(o.frame)] = struct{}{}
+		}
+	}
+	for _, data := range frames {
+		if _, ok := envelopes[string(data)]; !ok {
+			s.buffer = append(s.buffer, bufferedFrame{data: data, encoded: true})
+		}
+	}
+	for _, o := range pending {
+		s.buffer = append(s.buffer, bufferedFrame{data: o.raw, id: o.id})
+	}
+	limit := e.config.SessionConfig.BufferSize
+	if limit > 0 && len(s.buffer) > limit {
+		s.buffer = s.buffer[len(s.buffer)-limit:]
+	}
+	s.mu.Unlock()
+}
+
+// resumeSession 处理 RESUME 请求，把旧会话转移到当前连接
+func (e *Engine) resumeSession(c *Context) {
+	conn := c.connection
+	current := conn.getSession()
+	token, _ := bodyString(c.Request.Body, "session")
+
+	e.sessionsMu.Lock()
+	s, ok := e.sessions[token]
+	if ok && s != current {
+		s.mu.Lock()
+		ok = s.detached && time.Since(s.detachedAt) <= e.config.SessionConfig.Retention
+		s.mu.Unlock()
+	} else {
+		ok = false
+	}
+	if !ok {
+		e.sessionsMu.Unlock()
+		c.Response = &ResMessage{
+			ID:     c.Request.ID,
+			Status: StatusNotFound,
+			Header: DefaultHeader,
+			Body:   N{"resumed": false, "session": conn.SessionID()},
+		}
+		return
+	}
+	// 当前连接放弃新建的会话
+	if current != nil {
+		delete(e.sessions, current.token)
+	}
+	e.sessionsMu.Unlock()
+
+	s.mu.Lock()
+	old := s.conn
+	s.conn = conn
+	s.detached = false
+	buffer := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+	conn.setSession(s)
+
+	// 恢复身份、元数据和房间成员身份
+	conn.SetIdentity(old.Identity())
+	for k, v := range old.Metadata() {
+		conn.Set(k, v)
+	}
+	e.transferRooms(old, conn)
+
+	c.Response = &ResMessage{
+		ID:     c.Request.ID,
+		Status: StatusOK,
+		Header: DefaultHeader,
+		Body:   N{"resumed": true, "session": s.token, "buffered": len(buffer)},
+	}
+
+	// 补发断开期间缓存的消息
+	for _, f := range buffer {
+		if err := conn.replay(f); err != nil {
+			e.log(LogSession).Warn("session replay failed", "session", s.token, "conn", conn.id, "error", err)
+			break
+		}
+	}
+}
+
+// transferRooms 把旧连接的房间成员身份转移给新连接，不触发在线状态变化
+// 新连接已在房间内时移除旧连接，旧连接的在线身份因此全部离开时推送下线变化
+func (e *Engine) transferRooms(from, to *Connection) {
+	type leave struct {
+		room string
+		key  string
+	}
+	var leaves []leave
+
+	e.roomsMu.Lock()
+	for room, rs := range e.rooms {
+		key, ok := rs.members[from]
+		if !ok {
+			continue
+		}
+		if _, joined := rs.members[to]; joined {
+			if key, last := e.leaveLocked(room, from); last {
+				leaves = append(leaves, leave{room: room, key: key})
+			}
+			continue
+		}
+		delete(rs.members, from)
+		rs.members[to] = key
+	}
+	e.roomsMu.Unlock()
+
+	for _, l := range leaves {
+		e.publishPresence(l.room, nil, []string{l.key})
+	}
+}
+
+// bufferDetached 为所有满足条件的已断开会话缓存消息，返回缓存的会话数
+func (e *Engine) bufferDetached(data []byte, match func(c *Connection) bool) int {
+	if !e.config.SessionConfig.Enabled {
+		return 0
+	}
+	e.sessionsMu.Lock()
+	sessions := make([]*session, 0, len(e.sessions))
+	for _, s := range e.sessions {
+		sessions = append(sessions, s)
+	}
+	e.sessionsMu.Unlock()
+
+	buffered := 0
+	for _, s := range sessions {
+		s.mu.Lock()
+		conn, detached := s.conn, s.detached
+		s.mu.Unlock()
+		if detached && match(conn) && s.push(conn, data, e.config.SessionConfig.BufferSize) {
+			buffered++
+		}
+	}
+	return buffered
+}
+
+// expireSessions 定期清理超过保留期的已断开会话
+func (e *Engine) expireSessions() {
+	interval := e.config.SessionConfig.Retention / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.shutdownChan:
+			return
+		}
+
+		e.sweepSessions(time.Now().Add(-e.config.SessionConfig.Retention))
+	}
+}
+
+// sweepSessions 清理在before之前断开的会话
+// 在会话锁内取走缓存，只保留推送消息，已编码的响应和会话帧只对旧连接有意义
+func (e *Engine) sweepSessions(before time.Time) {
+	type expiredSession struct {
+		conn   *Connection
+		pushes [][]byte
+	}
+	var expired []expiredSession
+	e.sessionsMu.Lock()
+	for token, s := range e.sessions {
+		s.mu.Lock()
+		if s.detached && s.detachedAt.Before(before) {
+			x := expiredSession{conn: s.conn}
+			for _, f := range s.buffer {
+				if !f.encoded {
+					x.pushes = append(x.pushes, f.data)
+				}
+			}
+			s.buffer = nil
+			s.expired = true
+			expired = append(expired, x)
+			delete(e.sessions, token)
+		}
+		s.mu.Unlock()
+	}
+	e.sessionsMu.Unlock()
+
+	// 会话过期后才真正退出房间，尚未补发的推送消息转入离线存储
+	for _, x := range expired {
+		e.leaveAll(x.conn)
+		for _, data := range x.pushes {
+			e.storeOffline(x.conn.Identity(), data)
+		}
+	}
+}
+
+// bodyString 从请求体中读取字符串字段
+func bodyString(body any, key string) (string, bool) {
+	m, ok := body.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	v, ok := m[key].(string)
+	return v, ok
+}
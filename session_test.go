@@ -0,0 +1,185 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSessionEngine 创建启用会话恢复和在线状态推送的Engine
+func newSessionEngine() *Engine {
+	cfg := DefaultConfig()
+	cfg.SessionConfig.Enabled = true
+	cfg.PresenceConfig.Enabled = true
+	return NewWithConfig(cfg)
+}
+
+// readUntil 读取帧直到某个响应消息满足条件，同时返回在此之前读到的其他帧
+func readUntil(t *testing.T, fc FrameConn, match func(ResMessage) bool) (ResMessage, [][]byte) {
+	t.Helper()
+	var skipped [][]byte
+	for {
+		data, err := readTestFrame(t, fc)
+		if err != nil {
+			t.Fatalf("ReadFrame: %v", err)
+		}
+		var msg ResMessage
+		if json.Unmarshal(data, &msg) == nil && match(msg) {
+			return msg, skipped
+		}
+		skipped = append(skipped, data)
+	}
+}
+
+// newSessionConn 创建连接并读取服务端推送的会话令牌
+func newSessionConn(t *testing.T, e *Engine) (*Connection, FrameConn, string) {
+	t.Helper()
+	conn, client := newTestConn(t, e)
+	msg, _ := readUntil(t, client, func(m ResMessage) bool { return m.Header["path"] == SessionPath })
+	token, _ := bodyString(msg.Body, "session")
+	return conn, client, token
+}
+
+// dropSession 断开客户端并等待会话进入断开状态
+func dropSession(t *testing.T, conn *Connection, client FrameConn) {
+	t.Helper()
+	client.Close()
+	s := conn.getSession()
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.detached
+	})
+}
+
+// resume 发送 RESUME 请求，返回其响应以及先于响应到达的帧
+func resume(t *testing.T, client FrameConn, token string) (ResMessage, [][]byte) {
+	t.Helper()
+	req, _ := json.Marshal(ReqMessage{ID: "resume", Method: RESUME, Path: SessionPath, Body: N{"session": token}})
+	if err := client.WriteFrame(req); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+	return readUntil(t, client, func(m ResMessage) bool { return m.ID == "resume" })
+}
+
+func TestResumeAfterDrop(t *testing.T) {
+	e := newSessionEngine()
+	old, oldClient, token := newSessionConn(t, e)
+	old.SetIdentity("alice")
+	old.Join("lobby")
+	dropSession(t, old, oldClient)
+
+	e.BroadcastToRoom("lobby", []byte("while away"))
+
+	conn, client, _ := newSessionConn(t, e)
+	res, frames := resume(t, client, token)
+	if res.Status != StatusOK {
+		t.Fatalf("resume status = %d, want 200", res.Status)
+	}
+	if len(frames) != 1 || string(frames[0]) != "while away" {
+		t.Fatalf("replayed frames = %q, want buffered room message", frames)
+	}
+	if conn.Identity() != "alice" {
+		t.Fatalf("identity = %q, want alice", conn.Identity())
+	}
+	if members := e.RoomMembers("lobby"); len(members) != 1 || members[0] != conn {
+		t.Fatalf("room members = %v, want only the resumed connection", members)
+	}
+	if got := e.Presence().List("lobby"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Fatalf("presence = %v, want [alice]", got)
+	}
+}
+
+func TestResumeIntoJoinedRoom(t *testing.T) {
+	tests := []struct {
+		name         string
+		newIdentity  string
+		wantPresence func(conn *Connection) []string
+		wantLeaves   [][]string
+	}{
+		{"same identity", "alice", func(*Connection) []string { return []string{"alice"} }, nil},
+		{"different key", "", func(conn *Connection) []string { return []string{conn.ID()} }, [][]string{{"alice"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newSessionEngine()
+			old, oldClient, token := newSessionConn(t, e)
+			old.SetIdentity("alice")
+			old.Join("lobby")
+			dropSession(t, old, oldClient)
+
+			// 新连接在恢复会话之前已以自己的身份加入同一房间
+			conn, client, _ := newSessionConn(t, e)
+			conn.SetIdentity(tt.newIdentity)
+			conn.Join("lobby")
+			res, frames := resume(t, client, token)
+			if res.Status != StatusOK {
+				t.Fatalf("resume status = %d, want 200", res.Status)
+			}
+
+			want := tt.wantPresence(conn)
+			if got := e.Presence().List("lobby"); !reflect.DeepEqual(got, want) {
+				t.Fatalf("presence = %v, want %v", got, want)
+			}
+			e.roomsMu.Lock()
+			for key, n := range e.rooms["lobby"].identities {
+				if n <= 0 {
+					t.Errorf("identity %q left with count %d", key, n)
+				}
+			}
+			e.roomsMu.Unlock()
+
+			var leaves [][]string
+			for _, data := range frames {
+				var msg struct {
+					Body PresenceDiff `json:"body"`
+				}
+				if json.Unmarshal(data, &msg) == nil && len(msg.Body.Leaves) > 0 {
+					leaves = append(leaves, msg.Body.Leaves)
+				}
+			}
+			if !reflect.DeepEqual(leaves, tt.wantLeaves) {
+				t.Fatalf("presence leaves = %v, want %v", leaves, tt.wantLeaves)
+			}
+		})
+	}
+}
+
+func TestSweepSessionsStoresOnlyPushes(t *testing.T) {
+	store := NewMemoryMessageStore()
+	cfg := DefaultConfig()
+	cfg.SessionConfig.Enabled = true
+	cfg.OfflineConfig.Store = store
+	e := NewWithConfig(cfg)
+
+	// 旧连接不启动写协程，会话令牌和响应都留在发送通道中，断开时作为已编码帧缓存
+	_, server := NewPipe()
+	old := e.newConnection(server)
+	old.SetIdentity("alice")
+	e.startSession(old)
+	old.enqueue([]byte(`{"id":"req-1","status":200}`))
+	e.detachSession(old)
+	e.SendToIdentity("alice", []byte("while away"))
+
+	// 过期与新消息并发，新消息要么进入缓存要么直接保存
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			e.SendToIdentity("alice", []byte("late"))
+		}
+	}()
+	e.sweepSessions(time.Now().Add(time.Hour))
+	<-done
+
+	stored := loadStrings(t, store, "alice")
+	if len(stored) != 21 || stored[0] != "while away" {
+		t.Fatalf("stored %q, want the buffered push and every late message", stored)
+	}
+	for _, data := range stored {
+		if data != "while away" && data != "late" {
+			t.Fatalf("stored %q, want only push messages", data)
+		}
+	}
+}
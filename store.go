@@ -0,0 +1,562 @@
+package Nexus
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// MessageStore 持久化发给离线用户的消息，用户下次连接并绑定身份后补发
+type MessageStore interface {
+	// Save 为收件人追加一条消息
+	Save(recipient string, data []byte) error
+	// Load 按写入顺序返回收件人所有待投递的消息
+	Load(recipient string) ([][]byte, error)
+	// Delete 删除收件人所有待投递的消息
+	Delete(recipient string) error
+	// Trim 原子地删除收件人最早的n条消息，n大于消息数时全部删除，之后保存的消息不受影响
+	Trim(recipient string, n int) error
+	// Close 关闭存储
+	Close() error
+}
+
+// storeOffline 把消息保存给离线身份，未配置存储时不做任何事
+func (e *Engine) storeOffline(identity string, data []byte) {
+	store := e.config.OfflineConfig.Store
+	if store == nil || identity == "" {
+		return
+	}
+	e.storeMu.Lock()
+	defer e.storeMu.Unlock()
+	if err := store.Save(identity, data); err != nil {
+		e.log(LogSession).Error("failed to store offline message", "identity", identity, "error", err)
+	}
+}
+
+// OfflineRoomStore 是MessageStore的可选扩展，用于持久化离线身份所在的房间
+// 存储实现该接口时，Engine创建时会恢复这些记录，重启后继续为离线身份保存房间消息
+type OfflineRoomStore interface {
+	// SaveOfflineRoom 记录身份自since起离线时所在的房间
+	SaveOfflineRoom(room, identity string, since time.Time) error
+	// DeleteOfflineRoom 删除身份在房间中的离线记录
+	DeleteOfflineRoom(room, identity string) error
+	// LoadOfflineRooms 返回所有离线记录，房间名到身份及其离线时间的映射
+	LoadOfflineRooms() (map[string]map[string]time.Time, error)
+}
+
+// replayOffline 向刚绑定身份的连接补发离线消息
+// 身份立即不再被视为离线，消息由单独的协程按阻塞方式放入发送通道
+func (e *Engine) replayOffline(c *Connection) {
+	store := e.config.OfflineConfig.Store
+	identity := c.Identity()
+	if store == nil || identity == "" {
+		return
+	}
+
+	// 身份重新上线后不再代其接收房间消息
+	var rooms []string
+	e.roomsMu.Lock()
+	for room, identities := range e.offlineRooms {
+		if _, ok := identities[identity]; !ok {
+			continue
+		}
+		rooms = append(rooms, room)
+		delete(identities, identity)
+		if len(identities) == 0 {
+			delete(e.offlineRooms, room)
+		}
+	}
+	e.roomsMu.Unlock()
+	for _, room := range rooms {
+		e.forgetOfflineRoom(room, identity)
+	}
+
+	go e.deliverOffline(c, identity)
+}
+
+// deliverOffline 把身份的离线消息逐条放入连接的发送通道，通道已满时等待写出
+// 连接中途断开时只删除已发送的消息，其余消息留到下次上线补发
+func (e *Engine) deliverOffline(c *Connection, identity string) {
+	store := e.config.OfflineConfig.Store
+
+	e.storeMu.Lock()
+	// 同一身份的其他连接正在补发时由其负责
+	if _, ok := e.replaying[identity]; ok {
+		e.storeMu.Unlock()
+		return
+	}
+	messages, err := store.Load(identity)
+	if err != nil {
+		e.storeMu.Unlock()
+		e.log(LogSession).Error("failed to load offline messages", "identity", identity, "error", err)
+		return
+	}
+	if len(messages) == 0 {
+		e.storeMu.Unlock()
+		return
+	}
+	e.replaying[identity] = struct{}{}
+	e.storeMu.Unlock()
+
+	sent := 0
+	for _, data := range messages {
+		if err := c.sendWait(data); err != nil {
+			e.log(LogSession).Error("failed to replay offline message", "identity", identity, "conn", c.id, "error", err)
+			break
+		}
+		sent++
+	}
+
+	e.storeMu.Lock()
+	defer e.storeMu.Unlock()
+	delete(e.replaying, identity)
+	if sent == 0 {
+		return
+	}
+	if err := store.Trim(identity, sent); err != nil {
+		e.log(LogSession).Error("failed to delete offline messages", "identity", identity, "error", err)
+	}
+}
+
+// rememberOfflineRoomLocked 记录断开连接的身份所在的房间，之后发往这些房间的消息会为其保存
+// 返回是否需要记录，调用方需持有 roomsMu，并在释放锁后调用 persistOfflineRoom
+func (e *Engine) rememberOfflineRoomLocked(room, identity string, since time.Time) bool {
+	if e.config.OfflineConfig.Store == nil || identity == "" {
+		return false
+	}
+	if e.offlineRooms[room] == nil {
+		e.offlineRooms[room] = make(map[string]time.Time)
+	}
+	e.offlineRooms[room][identity] = since
+	return true
+}
+
+// persistOfflineRoom 把离线房间记录写入支持 OfflineRoomStore 的存储
+func (e *Engine) persistOfflineRoom(room, identity string, since time.Time) {
+	rs, ok := e.config.OfflineConfig.Store.(OfflineRoomStore)
+	if !ok {
+		return
+	}
+	e.storeMu.Lock()
+	defer e.storeMu.Unlock()
+	if err := rs.SaveOfflineRoom(room, identity, since); err != nil {
+		e.log(LogSession).Error("failed to store offline room", "room", room, "identity", identity, "error", err)
+	}
+}
+
+// forgetOfflineRoom 从支持 OfflineRoomStore 的存储中删除离线房间记录
+func (e *Engine) forgetOfflineRoom(room, identity string) {
+	rs, ok := e.config.OfflineConfig.Store.(OfflineRoomStore)
+	if !ok {
+		return
+	}
+	e.storeMu.Lock()
+	defer e.storeMu.Unlock()
+	if err := rs.DeleteOfflineRoom(room, identity); err != nil {
+		e.log(LogSession).Error("failed to delete offline room", "room", room, "identity", identity, "error", err)
+	}
+}
+
+// restoreOfflineRooms 从存储中恢复离线房间记录，在创建Engine时调用
+func (e *Engine) restoreOfflineRooms() {
+	rs, ok := e.config.OfflineConfig.Store.(OfflineRoomStore)
+	if !ok {
+		return
+	}
+	rooms, err := rs.LoadOfflineRooms()
+	if err != nil {
+		e.log(LogSession).Error("failed to load offline rooms", "error", err)
+		return
+	}
+	e.roomsMu.Lock()
+	for room, identities := range rooms {
+		for identity, since := range identities {
+			e.rememberOfflineRoomLocked(room, identity, since)
+		}
+	}
+	e.roomsMu.Unlock()
+}
+
+// expireOfflineRooms 定期清理超过保留期的离线房间记录
+func (e *Engine) expireOfflineRooms() {
+	retention := e.config.OfflineConfig.RoomRetention
+	interval := retention / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.shutdownChan:
+			return
+		}
+		e.sweepOfflineRooms(time.Now().Add(-retention))
+	}
+}
+
+// sweepOfflineRooms 删除在before之前离线的身份的房间记录
+func (e *Engine) sweepOfflineRooms(before time.Time) {
+	type expired struct {
+		room     string
+		identity string
+	}
+	var removed []expired
+
+	e.roomsMu.Lock()
+	for room, identities := range e.offlineRooms {
+		for identity, since := range identities {
+			if since.Before(before) {
+				removed = append(removed, expired{room: room, identity: identity})
+				delete(identities, identity)
+			}
+		}
+		if len(identities) == 0 {
+			delete(e.offlineRooms, room)
+		}
+	}
+	e.roomsMu.Unlock()
+
+	for _, r := range removed {
+		e.forgetOfflineRoom(r.room, r.identity)
+	}
+}
+
+// storeForOfflineMembers 为房间内的离线身份保存消息
+func (e *Engine) storeForOfflineMembers(room string, data []byte) {
+	if e.config.OfflineConfig.Store == nil {
+		return
+	}
+	for _, identity := range e.offlineMembers(room) {
+		e.storeOffline(identity, data)
+	}
+}
+
+// offlineMembers 返回房间内保留了成员资格的离线身份
+func (e *Engine) offlineMembers(room string) []string {
+	e.roomsMu.Lock()
+	defer e.roomsMu.Unlock()
+	identities := make([]string, 0, len(e.offlineRooms[room]))
+	for identity := range e.offlineRooms[room] {
+		identities = append(identities, identity)
+	}
+	return identities
+}
+
+// MemoryMessageStore 内存中的MessageStore实现，进程退出后消息丢失
+type MemoryMessageStore struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+}
+
+// NewMemoryMessageStore 创建内存消息存储
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{messages: make(map[string][][]byte)}
+}
+
+// Save 为收件人追加一条消息
+func (s *MemoryMessageStore) Save(recipient string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[recipient] = append(s.messages[recipient], data)
+	return nil
+}
+
+// Load 返回收件人所有待投递的消息
+func (s *MemoryMessageStore) Load(recipient string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([][]byte, len(s.messages[recipient]))
+	copy(messages, s.messages[recipient])
+	return messages, nil
+}
+
+// Delete 删除收件人所有待投递的消息
+func (s *MemoryMessageStore) Delete(recipient string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, recipient)
+	return nil
+}
+
+// Trim 删除收件人最早的n条消息
+func (s *MemoryMessageStore) Trim(recipient string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n >= len(s.messages[recipient]) {
+		delete(s.messages, recipient)
+		return nil
+	}
+	s.messages[recipient] = s.messages[recipient][n:]
+	return nil
+}
+
+// Close 内存存储无需关闭
+func (s *MemoryMessageStore) Close() error {
+	return nil
+}
+
+// fileStoreRecord 是文件存储日志中的一条记录
+type fileStoreRecord struct {
+	Op        string `json:"op"`
+	Recipient string `json:"recipient"`
+	Data      []byte `json:"data,omitempty"`
+	Room      string `json:"room,omitempty"`
+	Since     int64  `json:"since,omitempty"`
+	N         int    `json:"n,omitempty"`
+}
+
+// FileMessageStore 基于追加写日志文件的MessageStore实现，同时实现了 OfflineRoomStore
+// 每次写入都会同步到磁盘，打开时回放日志重建索引并压缩日志
+type FileMessageStore struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	messages map[string][][]byte
+	rooms    map[string]map[string]time.Time
+}
+
+var _ OfflineRoomStore = (*FileMessageStore)(nil)
+
+// OpenFileMessageStore 打开或创建日志文件
+func OpenFileMessageStore(path string) (*FileMessageStore, error) {
+	s := &FileMessageStore{
+		path:     path,
+		messages: make(map[string][][]byte),
+		rooms:    make(map[string]map[string]time.Time),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.Compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 回放日志文件
+func (s *FileMessageStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record fileStoreRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// 忽略写入中断留下的不完整记录
+			continue
+		}
+		switch record.Op {
+		case "save":
+			s.messages[record.Recipient] = append(s.messages[record.Recipient], record.Data)
+		case "delete":
+			delete(s.messages, record.Recipient)
+		case "trim":
+			s.trim(record.Recipient, record.N)
+		case "room":
+			s.setRoom(record.Room, record.Recipient, time.Unix(0, record.Since))
+		case "unroom":
+			s.unsetRoom(record.Room, record.Recipient)
+		}
+	}
+	return scanner.Err()
+}
+
+// append 向日志追加一条记录并同步到磁盘
+func (s *FileMessageStore) append(record fileStoreRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Save 为收件人追加一条消息
+func (s *FileMessageStore) Save(recipient string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(fileStoreRecord{Op: "save", Recipient: recipient, Data: data}); err != nil {
+		return err
+	}
+	s.messages[recipient] = append(s.messages[recipient], data)
+	return nil
+}
+
+// Load 返回收件人所有待投递的消息
+func (s *FileMessageStore) Load(recipient string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([][]byte, len(s.messages[recipient]))
+	copy(messages, s.messages[recipient])
+	return messages, nil
+}
+
+// Delete 删除收件人所有待投递的消息
+func (s *FileMessageStore) Delete(recipient string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.messages[recipient]; !ok {
+		return nil
+	}
+	if err := s.append(fileStoreRecord{Op: "delete", Recipient: recipient}); err != nil {
+		return err
+	}
+	delete(s.messages, recipient)
+	return nil
+}
+
+// Trim 删除收件人最早的n条消息，只追加一条记录
+func (s *FileMessageStore) Trim(recipient string, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n <= 0 || len(s.messages[recipient]) == 0 {
+		return nil
+	}
+	if err := s.append(fileStoreRecord{Op: "trim", Recipient: recipient, N: n}); err != nil {
+		return err
+	}
+	s.trim(recipient, n)
+	return nil
+}
+
+// trim 从索引中删除收件人最早的n条消息
+func (s *FileMessageStore) trim(recipient string, n int) {
+	if n >= len(s.messages[recipient]) {
+		delete(s.messages, recipient)
+		return
+	}
+	s.messages[recipient] = s.messages[recipient][n:]
+}
+
+// SaveOfflineRoom 记录身份自since起离线时所在的房间
+func (s *FileMessageStore) SaveOfflineRoom(room, identity string, since time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(fileStoreRecord{Op: "room", Recipient: identity, Room: room, Since: since.UnixNano()}); err != nil {
+		return err
+	}
+	s.setRoom(room, identity, since)
+	return nil
+}
+
+// DeleteOfflineRoom 删除身份在房间中的离线记录
+func (s *FileMessageStore) DeleteOfflineRoom(room, identity string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[room][identity]; !ok {
+		return nil
+	}
+	if err := s.append(fileStoreRecord{Op: "unroom", Recipient: identity, Room: room}); err != nil {
+		return err
+	}
+	s.unsetRoom(room, identity)
+	return nil
+}
+
+// LoadOfflineRooms 返回所有离线房间记录
+func (s *FileMessageStore) LoadOfflineRooms() (map[string]map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms := make(map[string]map[string]time.Time, len(s.rooms))
+	for room, identities := range s.rooms {
+		rooms[room] = make(map[string]time.Time, len(identities))
+		for identity, since := range identities {
+			rooms[room][identity] = since
+		}
+	}
+	return rooms, nil
+}
+
+// setRoom 在索引中记录离线房间
+func (s *FileMessageStore) setRoom(room, identity string, since time.Time) {
+	if s.rooms[room] == nil {
+		s.rooms[room] = make(map[string]time.Time)
+	}
+	s.rooms[room][identity] = since
+}
+
+// unsetRoom 从索引中删除离线房间
+func (s *FileMessageStore) unsetRoom(room, identity string) {
+	delete(s.rooms[room], identity)
+	if len(s.rooms[room]) == 0 {
+		delete(s.rooms, room)
+	}
+}
+
+// Compact 用当前未投递的消息和离线房间记录重写日志文件，去掉已删除的记录
+func (s *FileMessageStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for recipient, messages := range s.messages {
+		for _, data := range messages {
+			line, err := json.Marshal(fileStoreRecord{Op: "save", Recipient: recipient, Data: data})
+			if err != nil {
+				f.Close()
+				return err
+			}
+			w.Write(append(line, '\n'))
+		}
+	}
+	for room, identities := range s.rooms {
+		for identity, since := range identities {
+			line, err := json.Marshal(fileStoreRecord{Op: "room", Recipient: identity, Room: room, Since: since.UnixNano()})
+			if err != nil {
+				f.Close()
+				return err
+			}
+			w.Write(append(line, '\n'))
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+// Close 关闭日志文件
+func (s *FileMessageStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,256 @@
+package Nexus
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newStoreEngine 创建使用指定离线消息存储的Engine
+func newStoreEngine(store MessageStore, sendChannelSize int) *Engine {
+	cfg := DefaultConfig()
+	cfg.OfflineConfig.Store = store
+	cfg.ConnectionConfig.SendChannelSize = sendChannelSize
+	return NewWithConfig(cfg)
+}
+
+// saveMessages 为收件人保存n条编号的消息并返回它们
+func saveMessages(t *testing.T, store MessageStore, recipient string, n int) []string {
+	t.Helper()
+	messages := make([]string, n)
+	for i := range messages {
+		messages[i] = fmt.Sprintf("m%d", i)
+		if err := store.Save(recipient, []byte(messages[i])); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	return messages
+}
+
+// loadStrings 以字符串形式返回收件人待投递的消息
+func loadStrings(t *testing.T, store MessageStore, recipient string) []string {
+	t.Helper()
+	messages, err := store.Load(recipient)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	out := make([]string, len(messages))
+	for i, data := range messages {
+		out[i] = string(data)
+	}
+	return out
+}
+
+func TestReplayOfflineMoreThanSendChannel(t *testing.T) {
+	store := NewMemoryMessageStore()
+	want := saveMessages(t, store, "alice", 50)
+	e := newStoreEngine(store, 8)
+
+	conn, client := newTestConn(t, e)
+	conn.SetIdentity("alice")
+	for i, w := range want {
+		data, err := readTestFrame(t, client)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if string(data) != w {
+			t.Fatalf("frame %d = %q, want %q", i, data, w)
+		}
+	}
+	waitFor(t, func() bool { return len(loadStrings(t, store, "alice")) == 0 })
+}
+
+func TestReplayOfflineKeepsUnsentMessages(t *testing.T) {
+	store := NewMemoryMessageStore()
+	// 积压的消息多于发送通道和管道缓冲之和，断开时一定有未发送的消息
+	want := saveMessages(t, store, "alice", 4*pipeBufferSize)
+	e := newStoreEngine(store, 8)
+
+	conn, client := newTestConn(t, e)
+	conn.SetIdentity("alice")
+	for i := 0; i < 3; i++ {
+		if _, err := readTestFrame(t, client); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+	}
+	// 客户端中途断开，未放入发送通道的消息留在存储中
+	client.Close()
+	var rest []string
+	waitFor(t, func() bool {
+		e.storeMu.Lock()
+		_, replaying := e.replaying["alice"]
+		e.storeMu.Unlock()
+		rest = loadStrings(t, store, "alice")
+		return !replaying && len(rest) < len(want)
+	})
+	if len(rest) == 0 || len(rest) > len(want)-3 {
+		t.Fatalf("%d messages left, want the unsent suffix", len(rest))
+	}
+	if !reflect.DeepEqual(rest, want[len(want)-len(rest):]) {
+		t.Fatalf("left %v, want a suffix of the backlog", rest)
+	}
+}
+
+func TestFileMessageStore(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		ops       func(s *FileMessageStore)
+		wantAlice []string
+		wantRooms map[string]map[string]time.Time
+	}{
+		{"save", func(s *FileMessageStore) {
+			s.Save("alice", []byte("a"))
+			s.Save("alice", []byte("b"))
+		}, []string{"a", "b"}, map[string]map[string]time.Time{}},
+		{"delete", func(s *FileMessageStore) {
+			s.Save("alice", []byte("a"))
+			s.Delete("alice")
+			s.Save("alice", []byte("c"))
+		}, []string{"c"}, map[string]map[string]time.Time{}},
+		{"trim", func(s *FileMessageStore) {
+			saveMessages(t, s, "alice", 3)
+			s.Trim("alice", 2)
+			s.Save("alice", []byte("d"))
+		}, []string{"m2", "d"}, map[string]map[string]time.Time{}},
+		{"trim all", func(s *FileMessageStore) {
+			saveMessages(t, s, "alice", 2)
+			s.Trim("alice", 5)
+		}, []string{}, map[string]map[string]time.Time{}},
+		{"offline rooms", func(s *FileMessageStore) {
+			s.SaveOfflineRoom("lobby", "alice", since)
+			s.SaveOfflineRoom("lobby", "bob", since)
+			s.DeleteOfflineRoom("lobby", "bob")
+		}, []string{}, map[string]map[string]time.Time{"lobby": {"alice": since}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "offline.log")
+			s, err := OpenFileMessageStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.ops(s)
+			s.Close()
+
+			// 重新打开后回放日志得到相同的内容
+			s, err = OpenFileMessageStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if got := loadStrings(t, s, "alice"); !reflect.DeepEqual(got, tt.wantAlice) {
+				t.Fatalf("messages = %v, want %v", got, tt.wantAlice)
+			}
+			rooms, _ := s.LoadOfflineRooms()
+			if !reflect.DeepEqual(rooms, tt.wantRooms) {
+				t.Fatalf("rooms = %v, want %v", rooms, tt.wantRooms)
+			}
+		})
+	}
+}
+
+func TestMemoryMessageStoreTrim(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{0, []string{"m0", "m1", "m2"}},
+		{-1, []string{"m0", "m1", "m2"}},
+		{1, []string{"m1", "m2"}},
+		{3, []string{}},
+		{10, []string{}},
+	}
+	for _, tt := range tests {
+		s := NewMemoryMessageStore()
+		saveMessages(t, s, "alice", 3)
+		if err := s.Trim("alice", tt.n); err != nil {
+			t.Fatalf("Trim(%d): %v", tt.n, err)
+		}
+		if got := loadStrings(t, s, "alice"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Trim(%d) left %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestOfflineRoomsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offline.log")
+	store, err := OpenFileMessageStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newStoreEngine(store, 8)
+	conn, client := newTestConn(t, e)
+	conn.SetIdentity("alice")
+	conn.Join("lobby")
+	client.Close()
+	waitFor(t, func() bool {
+		rooms, _ := store.LoadOfflineRooms()
+		return len(rooms["lobby"]) == 1
+	})
+	store.Close()
+
+	// 重启后仍为离线身份保存房间消息
+	store, err = OpenFileMessageStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	e = newStoreEngine(store, 8)
+	e.BroadcastToRoom("lobby", []byte("after restart"))
+	if got := loadStrings(t, store, "alice"); !reflect.DeepEqual(got, []string{"after restart"}) {
+		t.Fatalf("stored = %v, want room message", got)
+	}
+
+	// 超过保留期的记录被清理
+	e.sweepOfflineRooms(time.Now().Add(time.Hour))
+	if rooms, _ := store.LoadOfflineRooms(); len(rooms) != 0 {
+		t.Fatalf("rooms after sweep = %v, want none", rooms)
+	}
+	e.BroadcastToRoom("lobby", []byte("expired"))
+	if got := loadStrings(t, store, "alice"); len(got) != 1 {
+		t.Fatalf("stored = %v, want no message after expiry", got)
+	}
+}
+
+func TestSendToIdentityClusterStoresOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		online    bool
+		wantStore int
+	}{
+		{"online on other node", true, 0},
+		{"offline everywhere", false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := NewMemoryBroker()
+			newNode := func() (*Engine, *MemoryMessageStore) {
+				store := NewMemoryMessageStore()
+				cfg := DefaultConfig()
+				cfg.ClusterConfig.Broker = broker
+				cfg.ClusterConfig.DeliveryTimeout = 50 * time.Millisecond
+				cfg.OfflineConfig.Store = store
+				return NewWithConfig(cfg), store
+			}
+			a, storeA := newNode()
+			b, _ := newNode()
+			defer a.stopCluster()
+			defer b.stopCluster()
+
+			if tt.online {
+				conn, _ := newTestConn(t, b)
+				conn.SetIdentity("alice")
+			}
+			if err := a.SendToIdentity("alice", []byte("hello")); err != nil {
+				t.Fatalf("SendToIdentity: %v", err)
+			}
+			time.Sleep(150 * time.Millisecond)
+			if got := loadStrings(t, storeA, "alice"); len(got) != tt.wantStore {
+				t.Fatalf("stored %v, want %d messages", got, tt.wantStore)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package Nexus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// topicRoomPrefix 主题订阅对应的房间名前缀，与普通房间区分
+const topicRoomPrefix = "topic:"
+
+// topicRoom 返回主题对应的房间名
+func topicRoom(topic string) string {
+	return topicRoomPrefix + topic
+}
+
+// isTopicRoom 判断房间是否为主题订阅房间
+func isTopicRoom(room string) bool {
+	return strings.HasPrefix(room, topicRoomPrefix)
+}
+
+// isTopicPattern 判断主题是否包含以 : 或 * 开头的路径段，段中其他位置的 : 和 * 不是通配符
+func isTopicPattern(topic string) bool {
+	for _, segment := range strings.Split(topic, "/") {
+		if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+			return true
+		}
+	}
+	return false
+}
+
+// topicPattern 使用路由树匹配主题
+// 每个模式单独建树，使 /rooms/:id 和 /rooms/*rest 这类在同一棵树中冲突的模式可以共存
+type topicPattern struct {
+	pattern string
+	root    *node
+}
+
+// newTopicPattern 为模式建立路由树，模式不合法时返回错误
+func newTopicPattern(pattern string) (p *topicPattern, err error) {
+	if pattern == "" || pattern[0] != '/' {
+		return nil, fmt.Errorf("invalid topic pattern %q: must begin with '/'", pattern)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p, err = nil, fmt.Errorf("invalid topic pattern %q: %v", pattern, r)
+		}
+	}()
+	root := new(node)
+	root.fullPath = "/"
+	root.addRoute(pattern, HandlerFuncList{func(*Context) {}})
+	return &topicPattern{pattern: pattern, root: root}, nil
+}
+
+// match 判断主题是否匹配模式，并返回提取出的参数
+func (p *topicPattern) match(topic string) (Params, bool) {
+	if topic == "" || topic[0] != '/' {
+		return nil, false
+	}
+	params := make(Params, 0, strings.Count(p.pattern, "/"))
+	skipped := make([]skippedNode, 0, strings.Count(topic, "/")+1)
+	value := p.root.getValue(topic, &params, &skipped, true)
+	if value.handlers == nil {
+		return nil, false
+	}
+	if value.params == nil {
+		return nil, true
+	}
+	return *value.params, true
+}
+
+// handleSubscription 处理订阅和取消订阅请求
+// 订阅必须匹配 SUBSCRIBE 路由，处理链返回2xx状态时连接加入主题
+// 取消订阅可以不声明路由，此时直接退出主题
+func (e *Engine) handleSubscription(c *Context) {
+	topic := c.Request.Path
+
+	if c.Request.Method == UNSUBSCRIBE {
+		if _, _, ok := e.ParsePath(UNSUBSCRIBE, topic); !ok {
+			c.Header = c.Request.Header
+			c.Response = &ResMessage{
+				ID:     c.Request.ID,
+				Header: c.Header,
+				Status: StatusOK,
+				Body:   N{},
+			}
+		} else {
+			e.HandleContext(c)
+		}
+	} else {
+		e.HandleContext(c)
+	}
+
+	if c.connection == nil || c.Response.Status < 200 || c.Response.Status >= 300 {
+		return
+	}
+
+	if c.Request.Method == SUBSCRIBE {
+		c.connection.Join(topicRoom(topic))
+	} else {
+		c.connection.Leave(topicRoom(topic))
+	}
+	e.log(LogRouter).Debug("subscription changed", "conn", c.connection.id, "method", c.Request.Method, "topic", topic)
+}
+
+// Publish 向订阅了主题的所有连接推送消息，消息头中的path为主题
+// 以 /rooms/:id 这样的模式订阅且模式匹配主题的连接也会收到消息，同时匹配多个订阅的连接只收到一次
+// 配置了Broker时，其他节点上的订阅者也会收到消息
+func (e *Engine) Publish(topic string, body any) {
+	msg := &ResMessage{
+		Status: StatusOK,
+		Header: header{"path": topic},
+		Body:   body,
+	}
+	data := msg.Bytes()
+	e.publishToLocalTopic(topic, data)
+	e.publish(channelTopic, topic, data)
+}
+
+// publishToLocalTopic 向本节点内订阅了主题的连接发送消息，并为订阅主题的离线身份保存消息
+func (e *Engine) publishToLocalTopic(topic string, data []byte) {
+	rooms := e.topicRooms(topic)
+	for _, conn := range e.topicSubscribers(rooms) {
+		if err := conn.Send(data); err != nil {
+			e.log(LogConnection).Warn("topic send failed", "topic", topic, "conn", conn.id, "error", err)
+		}
+	}
+	stored := make(map[string]bool)
+	for _, room := range rooms {
+		for _, identity := range e.offlineMembers(room) {
+			if !stored[identity] {
+				stored[identity] = true
+				e.storeOffline(identity, data)
+			}
+		}
+	}
+}
+
+// topicRooms 返回主题本身及匹配主题的模式对应的房间，包括只有离线身份的房间
+func (e *Engine) topicRooms(topic string) []string {
+	exact := topicRoom(topic)
+	var patterns []string
+	e.roomsMu.Lock()
+	for room := range e.rooms {
+		if room != exact && isTopicRoom(room) {
+			patterns = append(patterns, room)
+		}
+	}
+	for room := range e.offlineRooms {
+		if _, ok := e.rooms[room]; !ok && room != exact && isTopicRoom(room) {
+			patterns = append(patterns, room)
+		}
+	}
+	e.roomsMu.Unlock()
+
+	rooms := []string{exact}
+	for _, room := range patterns {
+		pattern := strings.TrimPrefix(room, topicRoomPrefix)
+		if !isTopicPattern(pattern) {
+			continue
+		}
+		p, err := newTopicPattern(pattern)
+		if err != nil {
+			continue
+		}
+		if _, ok := p.match(topic); ok {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
+// topicSubscribers 返回房间内的连接，同时在多个房间内的连接只返回一次
+func (e *Engine) topicSubscribers(rooms []string) []*Connection {
+	seen := make(map[*Connection]bool)
+	var conns []*Connection
+	for _, room := range rooms {
+		for _, conn := range e.RoomMembers(room) {
+			if !seen[conn] {
+				seen[conn] = true
+				conns = append(conns, conn)
+			}
+		}
+	}
+	return conns
+}
+
+// Subscribers 返回本节点内会收到主题推送的连接，包括以匹配的模式订阅的连接
+func (e *Engine) Subscribers(topic string) []*Connection {
+	return e.topicSubscribers(e.topicRooms(topic))
+}
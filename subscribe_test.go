@@ -0,0 +1,134 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIsTopicPattern(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"/chat", false},
+		{"/chat/a:b", false},
+		{"/files/a*b", false},
+		{"/rooms/:id", true},
+		{"/events/*rest", true},
+		{"/rooms/:id/messages", true},
+	}
+	for _, tt := range tests {
+		if got := isTopicPattern(tt.topic); got != tt.want {
+			t.Errorf("isTopicPattern(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestTopicPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		topic      string
+		wantOK     bool
+		wantParams Params
+	}{
+		{"/rooms/:id", "/rooms/7", true, Params{{Key: "id", Value: "7"}}},
+		{"/rooms/:id", "/rooms/7/messages", false, nil},
+		{"/rooms/:id/messages", "/rooms/7/messages", true, Params{{Key: "id", Value: "7"}}},
+		{"/events/*rest", "/events/a/b", true, Params{{Key: "rest", Value: "/a/b"}}},
+		{"/events/*rest", "/other", false, nil},
+		{"/rooms/:id", "rooms/7", false, nil},
+	}
+	for _, tt := range tests {
+		p, err := newTopicPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("newTopicPattern(%q): %v", tt.pattern, err)
+		}
+		params, ok := p.match(tt.topic)
+		if ok != tt.wantOK || (ok && !reflect.DeepEqual(params, tt.wantParams)) {
+			t.Errorf("%q.match(%q) = %v, %v, want %v, %v", tt.pattern, tt.topic, params, ok, tt.wantParams, tt.wantOK)
+		}
+	}
+
+	if _, err := newTopicPattern("rooms/:id"); err == nil {
+		t.Error("newTopicPattern accepted a pattern without leading '/'")
+	}
+}
+
+func TestPublishReachesPatternSubscribers(t *testing.T) {
+	e := New()
+	exact, exactClient := newTestConn(t, e)
+	pattern, patternClient := newTestConn(t, e)
+	both, bothClient := newTestConn(t, e)
+	other, otherClient := newTestConn(t, e)
+	exact.Join(topicRoom("/rooms/7"))
+	pattern.Join(topicRoom("/rooms/:id"))
+	both.Join(topicRoom("/rooms/7"))
+	both.Join(topicRoom("/rooms/*rest"))
+	other.Join(topicRoom("/rooms/:id/messages"))
+
+	e.Publish("/rooms/7", N{"text": "hi"})
+	for name, client := range map[string]FrameConn{"exact": exactClient, "pattern": patternClient, "both": bothClient} {
+		data, err := readTestFrame(t, client)
+		if err != nil {
+			t.Fatalf("%s subscriber: %v", name, err)
+		}
+		var msg ResMessage
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Header["path"] != "/rooms/7" {
+			t.Fatalf("%s subscriber received %q, want push with path /rooms/7", name, data)
+		}
+	}
+	// 同时匹配多个订阅的连接只收到一次，不匹配的模式收不到
+	for name, client := range map[string]FrameConn{"both": bothClient, "other": otherClient} {
+		client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		if data, err := client.ReadFrame(); err == nil {
+			t.Fatalf("%s subscriber received extra frame %q", name, data)
+		}
+	}
+
+	subs := e.Subscribers("/rooms/7")
+	if len(subs) != 3 {
+		t.Fatalf("Subscribers = %v, want exact, pattern and both", subs)
+	}
+	for _, conn := range subs {
+		if conn == other {
+			t.Fatal("Subscribers includes a non-matching pattern subscriber")
+		}
+	}
+}
+
+func TestClientPatternSubscription(t *testing.T) {
+	e := New()
+	client, _ := newTestClient(t, e, nil)
+
+	received := make(chan string, 4)
+	for _, topic := range []string{"/rooms/:id", "/rooms/*rest", "/rooms/8"} {
+		topic := topic
+		if err := client.Subscribe(topic, func(c *Context) {
+			received <- topic + " " + c.Param("id") + c.Param("rest")
+		}); err != nil {
+			t.Fatalf("Subscribe(%q): %v", topic, err)
+		}
+	}
+
+	push := &ResMessage{Status: StatusOK, Header: header{"path": "/rooms/7"}, Body: N{}}
+	for _, conn := range e.Connections() {
+		conn.Send(push.Bytes())
+	}
+	var got []string
+	for len(got) < 2 {
+		select {
+		case s := <-received:
+			got = append(got, s)
+		case <-time.After(time.Second):
+			t.Fatalf("received %v, want both matching subscriptions", got)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"/rooms/*rest /7", "/rooms/:id 7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,265 @@
+package Nexus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader 消息头中传递W3C追踪上下文的键
+const TraceparentHeader = "traceparent"
+
+// SpanContext 表示W3C traceparent中的追踪上下文
+type SpanContext struct {
+	// TraceID 32位十六进制的追踪ID
+	TraceID string
+	// SpanID 16位十六进制的跨度ID
+	SpanID string
+	// Sampled 是否被采样
+	Sampled bool
+}
+
+// ParseTraceparent 解析 00-<trace-id>-<span-id>-<flags> 格式的traceparent
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return SpanContext{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHexID(traceID, 32) || !isHexID(spanID, 16) || !isHexID(flags, 2) {
+		return SpanContext{}, false
+	}
+	flagBytes, _ := hex.DecodeString(flags)
+	return SpanContext{TraceID: traceID, SpanID: spanID, Sampled: flagBytes[0]&1 == 1}, true
+}
+
+// Traceparent 返回追踪上下文的traceparent表示
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// IsValid 判断追踪上下文是否有效
+func (sc SpanContext) IsValid() bool {
+	return isHexID(sc.TraceID, 32) && isHexID(sc.SpanID, 16)
+}
+
+// isHexID 判断字符串是否为指定长度、非全零的小写十六进制ID
+func isHexID(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f':
+		default:
+			return false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	// 标志位允许为00
+	return !zero || n == 2
+}
+
+// randomID 生成n字节的随机十六进制ID
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Span 表示一次消息处理或请求的追踪跨度
+// 未启用追踪时 Context.Span 返回nil，在nil上调用 SetAttribute 等方法不会产生任何效果
+type Span struct {
+	Name         string         `json:"name"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Kind         string         `json:"kind"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+
+	mu       sync.Mutex
+	exporter SpanExporter
+	sampled  bool
+	ended    bool
+}
+
+// 跨度类型
+const (
+	SpanKindServer = "server"
+	SpanKindClient = "client"
+)
+
+// startSpan 创建跨度，parent无效时开始新的被采样的追踪，否则沿用parent的采样标志
+func startSpan(name, kind string, parent SpanContext, exporter SpanExporter) *Span {
+	s := &Span{
+		Name:       name,
+		SpanID:     randomID(8),
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: make(map[string]any),
+		exporter:   exporter,
+		sampled:    true,
+	}
+	if parent.IsValid() {
+		s.TraceID = parent.TraceID
+		s.ParentSpanID = parent.SpanID
+		s.sampled = parent.Sampled
+	} else {
+		s.TraceID = randomID(16)
+	}
+	return s
+}
+
+// SpanContext 返回跨度的追踪上下文，用于向下游传递
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.TraceID, SpanID: s.SpanID, Sampled: s.sampled}
+}
+
+// SetAttribute 设置跨度属性
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError 记录跨度失败的原因
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = err.Error()
+}
+
+// Finish 结束跨度并导出，未被采样的跨度不导出，重复调用不会重复导出
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	if s.exporter != nil && s.sampled {
+		s.exporter.ExportSpan(s)
+	}
+}
+
+// Duration 返回跨度的持续时间
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SpanExporter 导出已结束的跨度
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter 将跨度保存在内存中，适用于测试
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewInMemoryExporter 创建内存导出器
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan 保存跨度
+func (x *InMemoryExporter) ExportSpan(span *Span) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.spans = append(x.spans, span)
+}
+
+// Spans 返回已导出的跨度
+func (x *InMemoryExporter) Spans() []*Span {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return append([]*Span(nil), x.spans...)
+}
+
+// Reset 清空已导出的跨度
+func (x *InMemoryExporter) Reset() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.spans = nil
+}
+
+// StdoutExporter 将跨度按行输出为JSON
+type StdoutExporter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewStdoutExporter 创建输出到w的导出器，w为nil时输出到标准输出
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &StdoutExporter{out: w}
+}
+
+// ExportSpan 输出一行JSON
+func (x *StdoutExporter) ExportSpan(span *Span) {
+	span.mu.Lock()
+	data, err := json.Marshal(span)
+	span.mu.Unlock()
+	if err != nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.out.Write(append(data, '\n'))
+}
+
+// spanContextKey context中保存跨度的键
+type spanContextKey struct{}
+
+// ContextWithSpan 返回携带跨度的context，客户端发出请求时会以该跨度为父跨度
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext 返回context中的跨度，不存在时返回nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// traceparentFromHeader 从消息头中读取追踪上下文
+func traceparentFromHeader(h header) SpanContext {
+	value, _ := h[TraceparentHeader].(string)
+	sc, _ := ParseTraceparent(value)
+	return sc
+}
@@ -0,0 +1,96 @@
+package Nexus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := []struct {
+		value  string
+		want   SpanContext
+		wantOK bool
+	}{
+		{"00-" + traceID + "-" + spanID + "-01", SpanContext{TraceID: traceID, SpanID: spanID, Sampled: true}, true},
+		{"00-" + traceID + "-" + spanID + "-00", SpanContext{TraceID: traceID, SpanID: spanID}, true},
+		{" 00-" + traceID + "-" + spanID + "-03 ", SpanContext{TraceID: traceID, SpanID: spanID, Sampled: true}, true},
+		{"01-" + traceID + "-" + spanID + "-01", SpanContext{}, false},
+		{"00-" + traceID + "-" + spanID, SpanContext{}, false},
+		{"00-00000000000000000000000000000000-" + spanID + "-01", SpanContext{}, false},
+		{"00-" + traceID + "-0000000000000000-01", SpanContext{}, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", SpanContext{}, false},
+		{"00-" + traceID + "-" + spanID + "-zz", SpanContext{}, false},
+		{"", SpanContext{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseTraceparent(tt.value)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("ParseTraceparent(%q) = %+v, %v, want %+v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+		if back, _ := ParseTraceparent(got.Traceparent()); ok && back != got {
+			t.Errorf("ParseTraceparent(%q) = %+v, want round trip to %+v", got.Traceparent(), back, got)
+		}
+	}
+}
+
+func TestSpanSampling(t *testing.T) {
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := []struct {
+		name         string
+		parent       SpanContext
+		wantSampled  bool
+		wantExported int
+	}{
+		{"new trace", SpanContext{}, true, 1},
+		{"sampled parent", SpanContext{TraceID: traceID, SpanID: spanID, Sampled: true}, true, 1},
+		{"unsampled parent", SpanContext{TraceID: traceID, SpanID: spanID}, false, 0},
+	}
+	for _, tt := range tests {
+		exporter := NewInMemoryExporter()
+		span := startSpan("GET /", SpanKindServer, tt.parent, exporter)
+		if got := span.SpanContext().Sampled; got != tt.wantSampled {
+			t.Errorf("%s: Sampled = %v, want %v", tt.name, got, tt.wantSampled)
+		}
+		span.Finish()
+		if got := len(exporter.Spans()); got != tt.wantExported {
+			t.Errorf("%s: exported %d spans, want %d", tt.name, got, tt.wantExported)
+		}
+	}
+}
+
+func TestRequestSpanUsesRouteNotPath(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	cfg := DefaultConfig()
+	cfg.TracingConfig.Exporter = exporter
+	e := NewWithConfig(cfg)
+	e.GET("/users/:id", func(c *Context) { c.JSON(StatusOK, N{}) })
+
+	_, client := newTestConn(t, e)
+	requests := []ReqMessage{
+		{ID: "1", Method: GET, Path: "/users/1"},
+		{ID: "2", Method: GET, Path: "/missing/abc"},
+		{ID: "3", Method: "BREW-a1b2", Path: "/users/3"},
+	}
+	for _, r := range requests {
+		req, _ := json.Marshal(r)
+		if err := client.WriteFrame(req); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+		readUntil(t, client, func(m ResMessage) bool { return m.ID == r.ID })
+	}
+
+	want := map[string]string{
+		"GET /users/:id":  "/users/:id",
+		"GET unmatched":   unmatchedRoute,
+		"other unmatched": unmatchedRoute,
+	}
+	waitFor(t, func() bool { return len(exporter.Spans()) == len(want) })
+	for _, span := range exporter.Spans() {
+		route, ok := want[span.Name]
+		if !ok || span.Attributes["nexus.route"] != route {
+			t.Errorf("span %q with route %v, want one of %v", span.Name, span.Attributes["nexus.route"], want)
+		}
+		delete(want, span.Name)
+	}
+}
@@ -0,0 +1,145 @@
+package Nexus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeWait 写入单个消息帧的超时时间
+const writeWait = 10 * time.Second
+
+// 关闭码，与WebSocket协议的关闭码一致
+const (
+	CloseNormalClosure     = 1000
+	CloseGoingAway         = 1001
+	CloseNoStatusReceived  = 1005
+	CloseAbnormalClosure   = 1006
+	ClosePolicyViolation   = 1008
+	CloseInternalServerErr = 1011
+)
+
+// CloseError 表示对端发送了带关闭码和原因的关闭帧
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("connection closed: %d %s", e.Code, e.Reason)
+}
+
+// FrameConn 表示一个可收发消息帧的底层连接
+// Connection 和 Client 都只通过该接口读写数据，与具体的传输方式无关
+type FrameConn interface {
+	// ReadFrame 阻塞读取下一个消息帧
+	ReadFrame() ([]byte, error)
+	// WriteFrame 写入一个消息帧，调用方需保证同一时间只有一个写入者
+	WriteFrame(data []byte) error
+	// Ping 发送心跳，对端响应时触发 SetPongHandler 设置的回调
+	Ping() error
+	// Close 关闭连接
+	Close() error
+	// CloseWithReason 发送带关闭码和原因的关闭帧后关闭连接
+	CloseWithReason(code int, reason string) error
+	// SetReadDeadline 设置读取超时时间，零值表示不超时
+	SetReadDeadline(t time.Time) error
+	// SetPongHandler 设置收到心跳响应时的回调
+	SetPongHandler(h func())
+	// RemoteAddr 返回对端地址
+	RemoteAddr() string
+}
+
+// Transport 负责建立客户端到服务端的帧连接
+type Transport interface {
+	// Dial 连接到指定地址
+	Dial(rawURL string, header http.Header) (FrameConn, error)
+}
+
+// wsFrameConn 基于gorilla/websocket的FrameConn实现
+type wsFrameConn struct {
+	ws *websocket.Conn
+}
+
+// NewWebSocketConn 将一个gorilla/websocket连接包装为FrameConn
+func NewWebSocketConn(ws *websocket.Conn) FrameConn {
+	return &wsFrameConn{ws: ws}
+}
+
+func (c *wsFrameConn) ReadFrame() ([]byte, error) {
+	_, data, err := c.ws.ReadMessage()
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return data, &CloseError{Code: ce.Code, Reason: ce.Text}
+	}
+	return data, err
+}
+
+func (c *wsFrameConn) WriteFrame(data []byte) error {
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *wsFrameConn) Ping() error {
+	return c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+}
+
+func (c *wsFrameConn) Close() error {
+	// 尽力发送关闭帧，忽略错误
+	c.ws.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(writeWait))
+	return c.ws.Close()
+}
+
+func (c *wsFrameConn) CloseWithReason(code int, reason string) error {
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	return c.ws.Close()
+}
+
+func (c *wsFrameConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *wsFrameConn) SetPongHandler(h func()) {
+	c.ws.SetPongHandler(func(string) error {
+		h()
+		return nil
+	})
+}
+
+func (c *wsFrameConn) RemoteAddr() string {
+	return c.ws.RemoteAddr().String()
+}
+
+// WebSocketTransport 基于gorilla/websocket的Transport实现，是客户端的默认传输方式
+type WebSocketTransport struct {
+	Dialer *websocket.Dialer
+}
+
+// Dial 建立WebSocket连接
+func (t *WebSocketTransport) Dial(rawURL string, header http.Header) (FrameConn, error) {
+	dialer := t.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	ws, _, err := dialer.Dial(rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	return NewWebSocketConn(ws), nil
+}
+
+// isUnexpectedCloseError 判断读取错误是否为非正常关闭
+func isUnexpectedCloseError(err error) bool {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		switch ce.Code {
+		case CloseNormalClosure, CloseGoingAway, CloseNoStatusReceived, CloseAbnormalClosure:
+			return false
+		}
+		return true
+	}
+	return false
+}
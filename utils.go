@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"path"
+	"reflect"
+	"runtime"
 	"time"
 )
 
@@ -14,6 +16,11 @@ func assert1(guard bool, text string) {
 	}
 }
 
+// nameOfFunction 返回函数的完整名称，如 main.listUsers
+func nameOfFunction(f any) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
 func lastChar(str string) uint8 {
 	if str == "" {
 		panic("The length of the string can't be 0")